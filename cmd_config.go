@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/pboehm/series/config"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show, change and validate the configuration",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var configShowOptionEffective, configOptionShowSecrets bool
+
+// shownConfig returns the config with redacted secrets unless --show-secrets
+// is given
+func shownConfig() config.Config {
+	if configOptionShowSecrets {
+		return appConfig
+	}
+	return config.RedactSecrets(appConfig)
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the configuration",
+	Long: `Show the configuration
+
+Every value can be overridden by an environment variable named after the key
+(e.g. SERIES_INDEX_FILE for IndexFile) and by --set key=value. Flags take
+precedence over environment variables, which take precedence over the config
+file and the defaults.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		shown := shownConfig()
+		if !configShowOptionEffective {
+			printJSON(shown)
+			return
+		}
+
+		for _, name := range config.FieldNames() {
+			value, err := config.GetField(shown, name)
+			HandleError(err)
+
+			var marshaled bytes.Buffer
+			encoder := json.NewEncoder(&marshaled)
+			encoder.SetEscapeHTML(false)
+			HandleError(encoder.Encode(value))
+
+			source := string(configSources[name])
+			if configSources[name] == config.SourceEnv {
+				source += " " + config.EnvName(name)
+			}
+
+			fmt.Printf("%-30s %-40s (%s)\n", name, strings.TrimSpace(marshaled.String()), source)
+		}
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get key",
+	Short: "Show a single configuration value",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.Help()
+			return
+		}
+
+		value, err := config.GetField(shownConfig(), args[0])
+		HandleError(err)
+
+		if str, ok := value.(string); ok {
+			fmt.Println(str)
+		} else {
+			printJSON(value)
+		}
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set key value",
+	Short: "Change a configuration value",
+	Long: `Change a configuration value
+
+Values of text settings are taken as they are, all other values are
+interpreted as JSON:
+
+  series config set UnknownSeriesPolicy queue
+  series config set IndexBackupCount 20
+  series config set ExtractorOrder '["script", "filesystem"]'
+
+With --profile the value is changed in the profile instead.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			cmd.Help()
+			return
+		}
+
+		fileConfig, err := config.ReadConfig(configFile, defaultConfig)
+		HandleError(err)
+
+		if configProfile != "" {
+			HandleError(config.SetProfileField(&fileConfig, configProfile, args[0], args[1]))
+		} else {
+			HandleError(config.SetField(&fileConfig, args[0], args[1]))
+		}
+		HandleError(config.WriteConfig(configFile, fileConfig))
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration for problems",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := config.Validate(appConfig)
+		if validationErr, ok := err.(*config.ValidationError); ok {
+			for _, problem := range validationErr.Problems {
+				fmt.Println(problem)
+			}
+			os.Exit(1)
+		}
+		HandleError(err)
+
+		LOG.Printf("%s is valid\n", configFile)
+	},
+}
+
+func printJSON(value interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	HandleError(encoder.Encode(value))
+}
+
+func init() {
+	configCmd.PersistentFlags().BoolVar(&configOptionShowSecrets, "show-secrets", false,
+		"Show secrets like the API token instead of redacting them")
+	configShowCmd.Flags().BoolVarP(&configShowOptionEffective, "effective", "e", false,
+		"Show the resolved values together with their sources")
+	configCmd.AddCommand(configShowCmd, configGetCmd, configSetCmd, configValidateCmd)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"github.com/pboehm/series/index"
+	"github.com/pboehm/series/renamer"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"strings"
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain path",
+	Short: "Explain how an episode would be renamed and indexed",
+	Long: `Explain how an episode would be renamed and indexed
+
+Shows the information parsed from the release name, the series names proposed
+by each extractor, the series matched in the index and how the language has
+been chosen. Neither the episode nor the index is changed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.Help()
+			return
+		}
+
+		episode, err := renamer.CreateEpisodeFromPath(args[0])
+		HandleError(err)
+
+		episode.RemoveTrashWords()
+
+		fmt.Printf("Parsed release name\n")
+		fmt.Printf("  series:   %s\n", episode.Series)
+		fmt.Printf("  episode:  S%02dE%02d\n", episode.Season, episode.Episode)
+		fmt.Printf("  name:     %s\n", episode.Name)
+		fmt.Printf("  language: %s\n", episode.Language)
+		fmt.Printf("  file:     %s\n", episode.EpisodeFile)
+
+		loadIndex()
+
+		result, matchErr := seriesIndex.MatchEpisode(episode)
+		printMatchResult(os.Stdout, result)
+
+		if matchErr != nil {
+			fmt.Printf("\nThe episode can't be indexed: %s\n", matchErr)
+			return
+		}
+
+		if !episode.HasValidEpisodeName() {
+			if title, found := seriesIndex.LookupEpisodeTitle(episode); found {
+				episode.Name = title
+			} else {
+				episode.SetDefaultEpisodeName()
+			}
+		}
+
+		fmt.Printf("\nResult\n")
+		fmt.Printf("  file name:        %s\n", episode.CleanedFileName())
+		fmt.Printf("  already in index: %t\n", result.AlreadyInIndex)
+	},
+}
+
+func printMatchResult(out io.Writer, result *index.MatchResult) {
+	fmt.Fprintf(out, "\nExtractors\n")
+	if len(result.Candidates) == 0 {
+		fmt.Fprintf(out, "  none configured, using the parsed series name\n")
+	}
+	for _, candidates := range result.Candidates {
+		if candidates.Err != nil {
+			fmt.Fprintf(out, "  %s: failed: %s\n", candidates.Extractor, candidates.Err)
+			continue
+		}
+		if len(candidates.Names) == 0 {
+			fmt.Fprintf(out, "  %s: no candidates\n", candidates.Extractor)
+			continue
+		}
+		language := ""
+		if candidates.Language != "" {
+			language = fmt.Sprintf(" (language %s)", candidates.Language)
+		}
+		fmt.Fprintf(out, "  %s: %s%s\n", candidates.Extractor, strings.Join(quoteAll(candidates.Names), ", "), language)
+	}
+
+	fmt.Fprintf(out, "\nMatch\n")
+	if result.Series == "" {
+		fmt.Fprintf(out, "  no candidate exists in the index\n")
+		return
+	}
+	if result.Extractor != "" {
+		fmt.Fprintf(out, "  series:   %s (%q proposed by %s)\n", result.Series, result.MatchedName, result.Extractor)
+	} else {
+		fmt.Fprintf(out, "  series:   %s (parsed series name)\n", result.Series)
+	}
+	fmt.Fprintf(out, "  language: %s (%s)\n", result.Language, result.LanguageReason)
+}
+
+func quoteAll(names []string) []string {
+	quoted := []string{}
+	for _, name := range names {
+		quoted = append(quoted, fmt.Sprintf("%q", name))
+	}
+	return quoted
+}
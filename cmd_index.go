@@ -1,45 +1,168 @@
 package main
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"github.com/pboehm/series/config"
+	"github.com/pboehm/series/hooks"
 	"github.com/pboehm/series/index"
 	"github.com/pboehm/series/util"
 	"github.com/spf13/cobra"
+	"io"
 	"os"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 )
 
 var seriesIndex *index.SeriesIndex
+
+// loadedIndexVersion is the version the index file had when it was loaded,
+// older indexes are migrated in memory and only written by writeIndex
+var loadedIndexVersion int
 var newSeriesLanguage string
 var newSeriesFirstEpisode string
 
 func loadIndex() {
+	HandleError(readIndex())
+}
+
+// readIndex is loadIndex returning the errors, for callers which must not exit
+func readIndex() error {
 	indexFilePath := appConfig.IndexFile
 	if !util.PathExists(indexFilePath) {
-		HandleError(errors.New(fmt.Sprintf("series index file %s does not exist, create one via `series index init`", indexFilePath)))
+		return errors.New(fmt.Sprintf("series index file %s does not exist, create one via `series index init`", indexFilePath))
 	}
 
 	LOG.Println("### Parsing series index ...")
 
 	var err error
-	seriesIndex, err = index.ParseSeriesIndex(indexFilePath)
-	HandleError(err)
+	if seriesIndex, err = index.ParseSeriesIndex(indexFilePath); err != nil {
+		return err
+	}
+
+	loadedIndexVersion = seriesIndex.GetVersion()
+	if err = seriesIndex.Migrate(); err != nil {
+		return err
+	}
+
+	return configureIndex()
+}
+
+// configureIndex adds the configured extractors and title providers to the
+// series index
+func configureIndex() error {
+	// add each SeriesNameExtractor in the configured order
+	for _, extractorType := range appConfig.ExtractorOrder {
+		if err := addExtractors(extractorType); err != nil {
+			return err
+		}
+	}
+
+	if appConfig.MetadataDirectory != "" {
+		seriesIndex.AddTitleProvider(index.MetadataDirectory{Directory: appConfig.MetadataDirectory})
+	}
+
+	return nil
+}
+
+func addExtractors(extractorType string) error {
+	switch extractorType {
+	case "filesystem":
+		seriesIndex.AddExtractor(index.FilesystemExtractor{})
+
+	case "regex":
+		var rules []index.RegexRule
+		for _, rule := range appConfig.SeriesNameRules {
+			rules = append(rules, index.RegexRule{Pattern: rule.Pattern, Series: rule.Series})
+		}
+
+		extractor, err := index.NewRegexExtractor(rules)
+		if err != nil {
+			return err
+		}
+		seriesIndex.AddExtractor(extractor)
+
+	case "mapping":
+		if appConfig.SeriesNameMappingFile == "" {
+			return nil
+		}
 
-	// add each SeriesNameExtractor
-	seriesIndex.AddExtractor(index.FilesystemExtractor{})
+		extractor, err := index.NewMappingFileExtractor(appConfig.SeriesNameMappingFile)
+		if err != nil {
+			return err
+		}
+		seriesIndex.AddExtractor(extractor)
+
+	case "script":
+		for _, script := range appConfig.ScriptExtractors {
+			seriesIndex.AddExtractor(index.ScriptExtractor{
+				ScriptPath: script.Path,
+				Timeout:    time.Duration(script.Timeout) * time.Second,
+			})
+		}
 
-	for _, script := range appConfig.ScriptExtractors {
-		seriesIndex.AddExtractor(index.ScriptExtractor{ScriptPath: script})
+	default:
+		return errors.New(fmt.Sprintf("unknown extractor '%s' in ExtractorOrder, use one of: %s",
+			extractorType, strings.Join(config.Extractors, ", ")))
 	}
+
+	return nil
 }
 
 func writeIndex() {
+	HandleError(saveIndex())
+}
+
+// saveIndex is writeIndex returning the errors, for callers which must not
+// exit. An error of an aborting index.written handler is returned as
+// *hooks.AbortError after the index has been written.
+func saveIndex() error {
+	return saveIndexWithPolicy(indexBackupPolicy())
+}
+
+// saveIndexWithPolicy is saveIndex backing up the current index file by the
+// supplied policy
+func saveIndexWithPolicy(policy index.BackupPolicy) error {
+	if loadedIndexVersion != 0 && loadedIndexVersion < index.CurrentVersion {
+		versionBackup, err := index.BackupVersion(appConfig.IndexFile, loadedIndexVersion)
+		if err != nil {
+			return err
+		}
+		LOG.Printf("### Migrated series index from version %d to %d (backup: %s)\n",
+			loadedIndexVersion, index.CurrentVersion, versionBackup)
+		loadedIndexVersion = index.CurrentVersion
+	}
+
+	backup, err := index.CreateBackup(appConfig.IndexFile, policy)
+	if err != nil {
+		LOG.Printf("!!! Creating a backup of the index wasn't possible: %s\n", err)
+	} else if backup != nil {
+		LOG.Printf("### Saved previous index version as backup %s\n", backup.Id)
+	}
+
 	LOG.Println("### Writing new index version ...")
-	seriesIndex.WriteToFile(appConfig.IndexFile)
+	if err = seriesIndex.WriteToFile(appConfig.IndexFile); err != nil {
+		return err
+	}
+
+	payload := hooks.Payload{"index_file": appConfig.IndexFile}
+	if backup != nil {
+		payload["backup"] = backup.Path
+	}
+	return emitEvent(hooks.IndexWritten, payload)
+}
+
+func indexBackupPolicy() index.BackupPolicy {
+	return index.BackupPolicy{
+		Directory: appConfig.IndexBackupDirectory,
+		Keep:      appConfig.IndexBackupCount,
+		KeepDaily: appConfig.IndexBackupDays,
+	}
 }
 
 var indexCmd = &cobra.Command{
@@ -70,17 +193,8 @@ var indexAddCmd = &cobra.Command{
 	Use:   "add [series, ...]",
 	Short: "Add series to index",
 	Run: func(cmd *cobra.Command, args []string) {
-		pattern := regexp.MustCompile("^S(?P<season>\\d+)E(?P<episode>\\d+)$")
-		groups, matched := util.NamedCaptureGroups(pattern, newSeriesFirstEpisode)
-		if !matched {
-			HandleError(errors.New("first episode does not have the correct format like: S01E01"))
-		}
-
-		season, seasonErr := strconv.Atoi(groups["season"])
-		episode, episodeErr := strconv.Atoi(groups["episode"])
-		if seasonErr != nil || episodeErr != nil || season <= 0 || episode <= 0 {
-			HandleError(errors.New("first episode is invalid"))
-		}
+		season, episode, err := parseFirstEpisode(newSeriesFirstEpisode)
+		HandleError(err)
 
 		callPreProcessingHook()
 		loadIndex()
@@ -93,7 +207,10 @@ var indexAddCmd = &cobra.Command{
 			if err != nil {
 				LOG.Printf(
 					"!!! Adding new index entry wasn't possible: %s\n", err)
+				continue
 			}
+
+			HandleError(emitEvent(hooks.SeriesAdded, hooks.Payload{"series": seriesName, "language": newSeriesLanguage}))
 		}
 
 		writeIndex()
@@ -101,6 +218,22 @@ var indexAddCmd = &cobra.Command{
 	},
 }
 
+func parseFirstEpisode(firstEpisode string) (int, int, error) {
+	pattern := regexp.MustCompile("^S(?P<season>\\d+)E(?P<episode>\\d+)$")
+	groups, matched := util.NamedCaptureGroups(pattern, firstEpisode)
+	if !matched {
+		return 0, 0, errors.New("first episode does not have the correct format like: S01E01")
+	}
+
+	season, seasonErr := strconv.Atoi(groups["season"])
+	episode, episodeErr := strconv.Atoi(groups["episode"])
+	if seasonErr != nil || episodeErr != nil || season <= 0 || episode <= 0 {
+		return 0, 0, errors.New("first episode is invalid")
+	}
+
+	return season, episode, nil
+}
+
 var indexRemoveCmd = &cobra.Command{
 	Use:   "remove [series, ...]",
 	Short: "Remove series from index",
@@ -150,6 +283,544 @@ var indexAliasCmd = &cobra.Command{
 	},
 }
 
+var indexUnaliasCmd = &cobra.Command{
+	Use:   "unalias series [alias, ...]",
+	Short: "Removes the supplied aliases from the given series",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			LOG.Println("You have to supply one series name and some aliases")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		callPreProcessingHook()
+		loadIndex()
+
+		series, args := args[0], args[1:]
+
+		for _, alias := range args {
+			LOG.Printf("Removing alias '%s' from '%s'\n", alias, series)
+			err := seriesIndex.RemoveAlias(series, alias)
+			if err != nil {
+				LOG.Printf("!!! Unable to remove the alias: %s\n", err)
+			}
+		}
+
+		writeIndex()
+		callPostProcessingHook()
+	},
+}
+
+var renameSeriesKeepAlias, renameSeriesFolder bool
+
+var indexRenameCmd = &cobra.Command{
+	Use:   "rename series new-name",
+	Short: "Renames the given series in the index",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			LOG.Println("You have to supply the series name and its new name")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		callPreProcessingHook()
+		loadIndex()
+
+		oldName := seriesIndex.SeriesNameInIndex(args[0])
+		newName := args[1]
+		if oldName == "" {
+			HandleError(errors.New(fmt.Sprintf("series '%s' does not exist in index", args[0])))
+		}
+
+		LOG.Printf("Renaming '%s' to '%s'\n", oldName, newName)
+		HandleError(seriesIndex.RenameSeries(oldName, newName, renameSeriesKeepAlias))
+
+		if renameSeriesFolder {
+			HandleError(renameSeriesFolderInLibrary(oldName, newName))
+		}
+
+		writeIndex()
+		callPostProcessingHook()
+	},
+}
+
+func renameSeriesFolderInLibrary(oldName, newName string) error {
+	if appConfig.LibraryDirectory == "" {
+		return errors.New("`LibraryDirectory` is not configured")
+	}
+
+	oldFolder := path.Join(appConfig.LibraryDirectory, oldName)
+	newFolder := path.Join(appConfig.LibraryDirectory, newName)
+
+	if !util.IsDirectory(oldFolder) {
+		LOG.Printf("!!! Series folder %s does not exist, skipping\n", oldFolder)
+		return nil
+	}
+	if util.PathExists(newFolder) {
+		return errors.New(fmt.Sprintf("series folder %s does already exist", newFolder))
+	}
+
+	LOG.Printf("Moving series folder %s to %s\n", oldFolder, newFolder)
+	return os.Rename(oldFolder, newFolder)
+}
+
+var indexLanguageCmd = &cobra.Command{
+	Use:   "lang",
+	Short: "Manage the languages a series is watched in",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var indexLanguageAddCmd = &cobra.Command{
+	Use:   "add series language",
+	Short: "Start watching the series in an additional language",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			LOG.Println("You have to supply one series name and a language")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		season, episode, err := parseFirstEpisode(newSeriesFirstEpisode)
+		HandleError(err)
+
+		callPreProcessingHook()
+		loadIndex()
+
+		series, language := args[0], args[1]
+
+		LOG.Printf("Watching '%s' in [%s] with %s as first episode\n", series, language, newSeriesFirstEpisode)
+		_, err = seriesIndex.AddLanguage(series, language, season, episode-1)
+		if err != nil {
+			LOG.Printf("!!! Adding the language wasn't possible: %s\n", err)
+		}
+
+		writeIndex()
+		callPostProcessingHook()
+	},
+}
+
+var indexLanguageRemoveCmd = &cobra.Command{
+	Use:   "remove series language",
+	Short: "Stop watching the series in the supplied language",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			LOG.Println("You have to supply one series name and a language")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		callPreProcessingHook()
+		loadIndex()
+
+		series, language := args[0], args[1]
+
+		LOG.Printf("Removing [%s] from '%s'\n", language, series)
+		_, err := seriesIndex.RemoveLanguage(series, language)
+		if err != nil {
+			LOG.Printf("!!! Removing the language wasn't possible: %s\n", err)
+		}
+
+		writeIndex()
+		callPostProcessingHook()
+	},
+}
+
+var indexStatusCmd = &cobra.Command{
+	Use:   "status series [active|paused|ended|dropped]",
+	Short: "Show or change the status of a series",
+	Long: `Show or change the status of a series
+
+Paused series are still listed by the streams commands but their links are not
+fetched. Dropped series are ignored by the streams commands and by the renamer.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 || len(args) > 2 {
+			LOG.Println("You have to supply one series name and optionally a new status")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		if len(args) == 1 {
+			loadIndex()
+
+			seriesName := seriesIndex.SeriesNameInIndex(args[0])
+			if seriesName == "" {
+				HandleError(errors.New(fmt.Sprintf("series '%s' does not exist in index", args[0])))
+			}
+
+			fmt.Println(seriesIndex.SeriesStatus(seriesName))
+			return
+		}
+
+		callPreProcessingHook()
+		loadIndex()
+
+		series, status := args[0], args[1]
+
+		LOG.Printf("Marking '%s' as %s\n", series, status)
+		HandleError(seriesIndex.SetSeriesStatus(series, status))
+
+		writeIndex()
+		callPostProcessingHook()
+	},
+}
+
+var indexTransferFormat, indexExportOutput string
+
+var indexExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the index as CSV or JSON",
+	Run: func(cmd *cobra.Command, args []string) {
+		loadIndex()
+
+		var output io.Writer = os.Stdout
+		if indexExportOutput != "" {
+			file, err := os.Create(indexExportOutput)
+			HandleError(err)
+			defer file.Close()
+			output = file
+		}
+
+		records := seriesIndex.ExportRecords()
+
+		switch transferFormat(indexExportOutput) {
+		case "csv":
+			HandleError(index.WriteRecordsCSV(output, records))
+		case "json":
+			HandleError(index.WriteRecordsJSON(output, records))
+		default:
+			HandleError(errors.New(fmt.Sprintf("unknown format '%s', use csv or json", indexTransferFormat)))
+		}
+	},
+}
+
+var indexImportCmd = &cobra.Command{
+	Use:   "import file",
+	Short: "Merge an exported CSV or JSON file into the index",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			LOG.Println("You have to supply the file that should be imported")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		file, err := os.Open(args[0])
+		HandleError(err)
+		defer file.Close()
+
+		var records []index.Record
+		switch transferFormat(args[0]) {
+		case "csv":
+			records, err = index.ReadRecordsCSV(file)
+		case "json":
+			records, err = index.ReadRecordsJSON(file)
+		default:
+			err = errors.New(fmt.Sprintf("unknown format '%s', use csv or json", indexTransferFormat))
+		}
+		HandleError(err)
+
+		callPreProcessingHook()
+		loadIndex()
+
+		LOG.Printf("### Importing %d records from %s ...\n", len(records), args[0])
+		report := seriesIndex.ImportRecords(records)
+
+		LOG.Printf("Added %d series, %d aliases, %d languages, %d ranges and %d episodes\n",
+			report.Series, report.Aliases, report.Languages, report.Ranges, report.Episodes)
+		for _, conflict := range report.Conflicts {
+			LOG.Printf("!!! Conflict: %s\n", conflict)
+		}
+
+		writeIndex()
+		callPostProcessingHook()
+	},
+}
+
+// transferFormat returns the format set by --format or guesses it from the
+// extension of the supplied file
+func transferFormat(file string) string {
+	if indexTransferFormat != "" {
+		return strings.ToLower(indexTransferFormat)
+	}
+
+	if strings.ToLower(path.Ext(file)) == ".json" {
+		return "json"
+	}
+
+	return "csv"
+}
+
+var indexDiffCmd = &cobra.Command{
+	Use:   "diff [a.xml] b.xml",
+	Short: "Show the differences between two series indexes",
+	Long: `Show the differences between two series indexes
+
+When only one file is supplied, the configured index is compared against it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var a, b *index.SeriesIndex
+
+		switch len(args) {
+		case 1:
+			loadIndex()
+			a, b = seriesIndex, parseIndexFile(args[0])
+		case 2:
+			a, b = parseIndexFile(args[0]), parseIndexFile(args[1])
+		default:
+			LOG.Println("You have to supply one or two index files")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		printIndexDiff(index.DiffIndexes(a, b))
+	},
+}
+
+var indexMergeCmd = &cobra.Command{
+	Use:   "merge other.xml",
+	Short: "Merge another series index into the configured one",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			LOG.Println("You have to supply the index file that should be merged")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		other := parseIndexFile(args[0])
+
+		callPreProcessingHook()
+		loadIndex()
+
+		LOG.Printf("### Merging %s into the index ...\n", args[0])
+		report := seriesIndex.Merge(other)
+
+		LOG.Printf("Added %d series, %d aliases, %d languages, %d ranges and %d episodes\n",
+			report.Series, report.Aliases, report.Languages, report.Ranges, report.Episodes)
+		for _, conflict := range report.Conflicts {
+			LOG.Printf("!!! Conflict: %s\n", conflict)
+		}
+
+		writeIndex()
+		callPostProcessingHook()
+	},
+}
+
+func parseIndexFile(file string) *index.SeriesIndex {
+	if !util.PathExists(file) {
+		HandleError(errors.New(fmt.Sprintf("series index file %s does not exist", file)))
+	}
+
+	parsed, err := index.ParseMigratedSeriesIndex(file)
+	HandleError(err)
+
+	return parsed
+}
+
+func printIndexDiff(diff *index.IndexDiff) {
+	if diff.Empty() {
+		fmt.Println("no differences")
+		return
+	}
+
+	for _, record := range diff.Removed {
+		fmt.Printf("- %s\n", record)
+	}
+	for _, record := range diff.Added {
+		fmt.Printf("+ %s\n", record)
+	}
+}
+
+var indexScanPreview bool
+var indexScanLanguage string
+
+var indexScanCmd = &cobra.Command{
+	Use:   "scan [library-root]",
+	Short: "Add all episodes of an existing library to the index",
+	Long: `Add all episodes of an existing library to the index
+
+The library has to be organized like Series/Season/S01E01 - Name.ext. Series
+that do not exist in the index are created. When no library root is supplied
+the configured LibraryDirectory is scanned.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		root := appConfig.LibraryDirectory
+		if len(args) == 1 {
+			root = args[0]
+		}
+
+		if root == "" || !util.IsDirectory(root) {
+			HandleError(errors.New(fmt.Sprintf("library root '%s' is not a directory", root)))
+		}
+
+		if !indexScanPreview {
+			callPreProcessingHook()
+		}
+		loadIndex()
+
+		LOG.Printf("### Scanning library %s ...\n", root)
+		report, err := seriesIndex.ScanLibrary(root, indexScanLanguage)
+		HandleError(err)
+
+		for _, series := range report.CreatedSeries {
+			LOG.Printf("+++ %s\n", series)
+		}
+		for _, episode := range report.Added {
+			LOG.Printf("+ %s [%s]: %s\n", episode.Series, episode.Language, episode.Name)
+		}
+		for _, file := range report.Unplaced {
+			LOG.Printf("!!! %s: %s\n", file.Path, file.Reason)
+		}
+
+		LOG.Printf("Found %d new series and %d new episodes, %d episodes already indexed, %d files unplaced\n",
+			len(report.CreatedSeries), len(report.Added), report.Existing, len(report.Unplaced))
+
+		if indexScanPreview {
+			LOG.Println("### Preview mode, the index is left unchanged")
+			return
+		}
+
+		writeIndex()
+		callPostProcessingHook()
+	},
+}
+
+var indexRangeLanguage string
+
+var indexRangeCmd = &cobra.Command{
+	Use:   "range",
+	Short: "Manage ranges of watched episodes",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var indexRangeAddCmd = &cobra.Command{
+	Use:   "add series from to",
+	Short: "Mark all episodes between from and to (like S01E01 S04E22) as watched",
+	Run: func(cmd *cobra.Command, args []string) {
+		modifyRange(cmd, args, "Marking %s of '%s' [%s] as watched\n", (*index.SeriesIndex).AddRange)
+	},
+}
+
+var indexRangeRemoveCmd = &cobra.Command{
+	Use:   "remove series from to",
+	Short: "Remove a range of watched episodes",
+	Run: func(cmd *cobra.Command, args []string) {
+		modifyRange(cmd, args, "Removing range %s from '%s' [%s]\n", (*index.SeriesIndex).RemoveRange)
+	},
+}
+
+func modifyRange(cmd *cobra.Command, args []string, message string,
+	modify func(*index.SeriesIndex, string, string, index.EpisodeNumber, index.EpisodeNumber) error) {
+
+	if len(args) != 3 {
+		LOG.Println("You have to supply one series name and the first and last episode")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	from, err := index.ParseEpisodeNumber(args[1])
+	HandleError(err)
+	to, err := index.ParseEpisodeNumber(args[2])
+	HandleError(err)
+
+	callPreProcessingHook()
+	loadIndex()
+
+	series := seriesIndex.SeriesNameInIndex(args[0])
+	if series == "" {
+		HandleError(errors.New(fmt.Sprintf("series '%s' does not exist in index", args[0])))
+	}
+
+	language := indexRangeLanguage
+	if language == "" {
+		languages := seriesIndex.SeriesLanguages(series)
+		if len(languages) != 1 {
+			HandleError(errors.New("series is watched in multiple languages, supply one with --lang"))
+		}
+		language = languages[0]
+	}
+
+	LOG.Printf(message, fmt.Sprintf("%s-%s", from, to), series, language)
+	HandleError(modify(seriesIndex, series, language, from, to))
+
+	writeIndex()
+	callPostProcessingHook()
+}
+
+var indexBackupsCmd = &cobra.Command{
+	Use:   "backups",
+	Short: "List the available backups of the index",
+	Run: func(cmd *cobra.Command, args []string) {
+		backups, err := index.ListBackups(appConfig.IndexBackupDirectory)
+		HandleError(err)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+		for _, backup := range backups {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", backup.Id, backup.Time.Format("2006-01-02 15:04:05"), backup.Path)
+		}
+		w.Flush()
+	},
+}
+
+var indexRestoreConfirmed bool
+
+var indexRestoreCmd = &cobra.Command{
+	Use:   "restore backup-id",
+	Short: "Restore a backup of the index",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			LOG.Println("You have to supply the id of the backup, see `series index backups`")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		backup, err := index.FindBackup(appConfig.IndexBackupDirectory, args[0])
+		HandleError(err)
+
+		callPreProcessingHook()
+		loadIndex()
+
+		restored := parseIndexFile(backup.Path)
+
+		LOG.Printf("### Changes when restoring backup %s:\n", backup.Id)
+		printIndexDiff(index.DiffIndexes(seriesIndex, restored))
+
+		if !indexRestoreConfirmed && !confirm("Restore this backup?") {
+			LOG.Println("Aborted")
+			return
+		}
+
+		seriesIndex = restored
+		HandleError(configureIndex())
+
+		// the current index is backed up so that restoring can be undone, the
+		// restored backup is kept even when the policy would remove it
+		policy := indexBackupPolicy()
+		policy.Retain = backup.Path
+		HandleError(saveIndexWithPolicy(policy))
+		LOG.Printf("### Restored backup %s\n", backup.Id)
+
+		callPostProcessingHook()
+	},
+}
+
+// confirm asks the user the supplied question on stderr and returns true if it
+// has been answered with yes
+var stdin = bufio.NewReader(os.Stdin)
+
+func confirm(question string) bool {
+	answer := strings.ToLower(ask(question + " [y/N]"))
+	return answer == "y" || answer == "yes"
+}
+
+// ask prints the question and returns the trimmed answer from stdin
+func ask(question string) string {
+	fmt.Fprintf(os.Stderr, "%s ", question)
+
+	answer, _ := stdin.ReadString('\n')
+	return strings.TrimSpace(answer)
+}
+
 var indexListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all series in index",
@@ -164,12 +835,22 @@ var indexListCmd = &cobra.Command{
 				aliases = append(aliases, alias.To)
 			}
 
+			var languages []string
+			for _, set := range series.EpisodeSets {
+				position := "-"
+				if season, episode, found := set.LastEpisode(); found {
+					position = fmt.Sprintf("S%02dE%02d", season, episode)
+				}
+				languages = append(languages, fmt.Sprintf("%s: %s", set.GetLanguage(), position))
+			}
+
 			joined := ""
 			if len(aliases) > 0 {
 				joined = fmt.Sprintf("aliases: %s", strings.Join(aliases, "; "))
 			}
 
-			fmt.Fprintf(w, "%s\t%s\n", series.Name, joined)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+				series.Name, series.GetStatus(), strings.Join(languages, ", "), joined)
 		}
 		w.Flush()
 	},
@@ -181,5 +862,36 @@ func init() {
 	indexAddCmd.Flags().StringVarP(&newSeriesFirstEpisode, "first-episode", "f", "S01E01",
 		"the first episode that you are interested in")
 
-	indexCmd.AddCommand(indexInitCmd, indexAddCmd, indexRemoveCmd, indexAliasCmd, indexListCmd)
+	indexLanguageAddCmd.Flags().StringVarP(&newSeriesFirstEpisode, "first-episode", "f", "S01E01",
+		"the first episode that you are interested in")
+	indexLanguageCmd.AddCommand(indexLanguageAddCmd, indexLanguageRemoveCmd)
+
+	indexExportCmd.Flags().StringVarP(&indexTransferFormat, "format", "F", "",
+		"format of the export (csv/json), guessed from --output if not set")
+	indexExportCmd.Flags().StringVarP(&indexExportOutput, "output", "o", "",
+		"write the export to this file instead of stdout")
+	indexImportCmd.Flags().StringVarP(&indexTransferFormat, "format", "F", "",
+		"format of the imported file (csv/json), guessed from its extension if not set")
+
+	indexScanCmd.Flags().BoolVarP(&indexScanPreview, "preview", "p", false,
+		"only show what would be added to the index")
+	indexScanCmd.Flags().StringVarP(&indexScanLanguage, "lang", "l", index.DefaultLanguage,
+		"language of episodes whose language can't be determined")
+
+	indexRangeCmd.PersistentFlags().StringVarP(&indexRangeLanguage, "lang", "l", "",
+		"language of the range, can be omitted when the series is watched in one language")
+	indexRangeCmd.AddCommand(indexRangeAddCmd, indexRangeRemoveCmd)
+
+	indexRestoreCmd.Flags().BoolVarP(&indexRestoreConfirmed, "yes", "y", false,
+		"restore without asking for confirmation")
+
+	indexRenameCmd.Flags().BoolVarP(&renameSeriesKeepAlias, "keep-alias", "k", true,
+		"keep the old name as alias of the series")
+	indexRenameCmd.Flags().BoolVarP(&renameSeriesFolder, "rename-folder", "m", false,
+		"also rename the series folder in the configured LibraryDirectory")
+
+	indexCmd.AddCommand(indexInitCmd, indexAddCmd, indexRemoveCmd, indexAliasCmd, indexUnaliasCmd,
+		indexRenameCmd, indexLanguageCmd, indexStatusCmd, indexExportCmd,
+		indexImportCmd, indexDiffCmd, indexMergeCmd, indexScanCmd,
+		indexRangeCmd, indexBackupsCmd, indexRestoreCmd, indexListCmd)
 }
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var libraryVerifyFix, libraryVerifyRemoveMissing bool
+
+var libraryCmd = &cobra.Command{
+	Use:   "library",
+	Short: "Manage the episode library",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var libraryVerifyCmd = &cobra.Command{
+	Use:   "verify [library-root]",
+	Short: "Cross-check the index against the files in the library",
+	Long: `Cross-check the index against the files in the library
+
+Reports episodes that are indexed but missing on disk, files that are not part
+of the index and episodes whose names differ. With --fix the index is changed
+so that it matches the library. Episodes missing on disk are kept, as they are
+usually watched and deleted afterwards, unless --remove-missing is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		root := appConfig.LibraryDirectory
+		if len(args) == 1 {
+			root = args[0]
+		}
+
+		if libraryVerifyRemoveMissing && !libraryVerifyFix {
+			HandleError(errors.New("--remove-missing can only be used together with --fix"))
+		}
+
+		if root == "" {
+			HandleError(errors.New("no library root supplied and `LibraryDirectory` is not configured"))
+		}
+		_, err := os.Stat(root)
+		HandleError(err)
+
+		if libraryVerifyFix {
+			callPreProcessingHook()
+		}
+		loadIndex()
+
+		LOG.Printf("### Verifying library %s ...\n", root)
+		report, err := seriesIndex.VerifyLibrary(root)
+		HandleError(err)
+
+		for _, entry := range report.MissingOnDisk {
+			fmt.Printf("missing on disk: %s [%s] %s\n", entry.Series, entry.Language, entry.IndexName)
+		}
+		for _, entry := range report.NotInIndex {
+			fmt.Printf("not in index:    %s\n", entry.Path)
+		}
+		for _, entry := range report.Mismatches {
+			fmt.Printf("name mismatch:   %s [%s] %s != %s\n", entry.Series, entry.Language, entry.IndexName, entry.FileName)
+		}
+		for _, file := range report.Unplaced {
+			fmt.Printf("unplaced:        %s (%s)\n", file.Path, file.Reason)
+		}
+
+		if report.Clean() {
+			LOG.Println("Index and library are in sync")
+			return
+		}
+
+		if !libraryVerifyFix {
+			return
+		}
+
+		LOG.Println("### Fixing the index from disk ...")
+		for _, entry := range seriesIndex.FixFromLibrary(report, libraryVerifyRemoveMissing) {
+			LOG.Printf("!!! Could not add %s, series '%s' does not exist in index\n", entry.Path, entry.Series)
+		}
+
+		writeIndex()
+		callPostProcessingHook()
+	},
+}
+
+func init() {
+	libraryVerifyCmd.Flags().BoolVarP(&libraryVerifyFix, "fix", "f", false,
+		"change the index so that it matches the library")
+	libraryVerifyCmd.Flags().BoolVar(&libraryVerifyRemoveMissing, "remove-missing", false,
+		"remove episodes missing on disk from the index when fixing it")
+
+	libraryCmd.AddCommand(libraryVerifyCmd)
+}
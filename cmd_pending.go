@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pboehm/series/config"
+	"github.com/pboehm/series/hooks"
+	"github.com/pboehm/series/index"
+	"github.com/pboehm/series/renamer"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// handleUnknownSeries applies the UnknownSeriesPolicy to an episode whose
+// series is not part of the index. When the series has been added or aliased,
+// the episode is added to the index again.
+func handleUnknownSeries(entryPath string, episode *renamer.Episode, result *index.MatchResult, err error) (*index.MatchResult, error) {
+	callUnknownSeriesHook(entryPath, episode.Series)
+
+	payload := episodePayload(episode)
+	payload["path"] = entryPath
+	HandleError(emitEvent(hooks.SeriesUnknown, payload))
+
+	switch appConfig.UnknownSeriesPolicy {
+	case config.UnknownSeriesIgnore, "":
+		return result, err
+
+	case config.UnknownSeriesQueue:
+		queuePendingEpisode(entryPath, episode)
+		return result, err
+
+	case config.UnknownSeriesAutoAdd:
+		if addErr := addUnknownSeries(episode); addErr != nil {
+			return result, addErr
+		}
+		return seriesIndex.AddEpisode(episode)
+
+	case config.UnknownSeriesPrompt:
+		return promptUnknownSeries(entryPath, episode, result, err)
+
+	default:
+		return result, errors.New(fmt.Sprintf("unknown UnknownSeriesPolicy '%s', use one of: %s",
+			appConfig.UnknownSeriesPolicy, strings.Join(config.UnknownSeriesPolicies, ", ")))
+	}
+}
+
+// addUnknownSeries creates the series with the episode as first episode.
+// Episodes without a detected language get the default language of the index.
+func addUnknownSeries(episode *renamer.Episode) error {
+	language := episode.Language
+	if language == "" {
+		language = index.DefaultLanguage
+	}
+
+	LOG.Printf("---> creating new index entry for '%s' [%s]\n", episode.Series, language)
+	_, err := seriesIndex.AddSeries(episode.Series, language, episode.Season, episode.Episode-1)
+	if err == nil {
+		HandleError(emitEvent(hooks.SeriesAdded, hooks.Payload{"series": episode.Series, "language": language}))
+	}
+	return err
+}
+
+func promptUnknownSeries(entryPath string, episode *renamer.Episode, result *index.MatchResult, err error) (*index.MatchResult, error) {
+	LOG.Printf("??? '%s' belongs to the unknown series '%s'\n", entryPath, episode.Series)
+
+	for {
+		choice := ask("[a]dd as new series, a[l]ias to an existing series, [q]ueue as pending, [s]kip:")
+
+		switch choice {
+		case "a":
+			if addErr := addUnknownSeries(episode); addErr != nil {
+				return result, addErr
+			}
+			return seriesIndex.AddEpisode(episode)
+
+		case "l":
+			existing := seriesIndex.SeriesNameInIndex(ask("Name of the existing series:"))
+			if existing == "" {
+				LOG.Println("!!! this series does not exist in the index")
+				continue
+			}
+
+			if aliasErr := seriesIndex.AliasSeries(existing, episode.Series); aliasErr != nil {
+				return result, aliasErr
+			}
+			LOG.Printf("---> '%s' is now an alias for '%s'\n", episode.Series, existing)
+			return seriesIndex.AddEpisode(episode)
+
+		case "q":
+			queuePendingEpisode(entryPath, episode)
+			return result, err
+
+		case "s", "":
+			return result, err
+		}
+	}
+}
+
+func pendingFile() string {
+	if appConfig.PendingFile == "" {
+		HandleError(errors.New("`PendingFile` is not configured"))
+	}
+	return appConfig.PendingFile
+}
+
+func queuePendingEpisode(entryPath string, episode *renamer.Episode) {
+	absolutePath, err := filepath.Abs(entryPath)
+	HandleError(err)
+
+	pending, err := index.LoadPendingList(pendingFile())
+	HandleError(err)
+
+	if pending.Add(absolutePath, episode) {
+		HandleError(pending.Save(pendingFile()))
+		LOG.Printf("---> queued as pending, see `series pending`\n")
+	}
+}
+
+func removePendingEpisode(entryPath string) {
+	absolutePath, err := filepath.Abs(entryPath)
+	HandleError(err)
+
+	pending, err := index.LoadPendingList(pendingFile())
+	HandleError(err)
+
+	if pending.Remove(absolutePath) {
+		HandleError(pending.Save(pendingFile()))
+	}
+}
+
+var pendingCmd = &cobra.Command{
+	Use:   "pending",
+	Short: "List episodes of unknown series which are queued as pending",
+	Run: func(cmd *cobra.Command, args []string) {
+		pending, err := index.LoadPendingList(pendingFile())
+		HandleError(err)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+		for _, episode := range pending.Episodes {
+			fmt.Fprintf(w, "%s\tS%02dE%02d\t%s\t%s\t%s\n", episode.Series, episode.Season, episode.Episode,
+				episode.Language, episode.Queued.Format("2006-01-02 15:04"), episode.Path)
+		}
+		w.Flush()
+	},
+}
+
+var pendingRemoveCmd = &cobra.Command{
+	Use:   "remove [path, ...]",
+	Short: "Remove episodes from the pending list",
+	Run: func(cmd *cobra.Command, args []string) {
+		pending, err := index.LoadPendingList(pendingFile())
+		HandleError(err)
+
+		for _, arg := range args {
+			absolutePath, err := filepath.Abs(arg)
+			HandleError(err)
+
+			if !pending.Remove(absolutePath) {
+				LOG.Printf("!!! '%s' is not pending\n", arg)
+			}
+		}
+
+		HandleError(pending.Save(pendingFile()))
+	},
+}
+
+var pendingClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all episodes from the pending list",
+	Run: func(cmd *cobra.Command, args []string) {
+		pending := &index.PendingList{Episodes: []index.PendingEpisode{}}
+		HandleError(pending.Save(pendingFile()))
+	},
+}
+
+func init() {
+	pendingCmd.AddCommand(pendingRemoveCmd, pendingClearCmd)
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"github.com/pboehm/series/hooks"
+	"github.com/pboehm/series/index"
 	"github.com/pboehm/series/renamer"
 	"github.com/spf13/cobra"
 	"io/ioutil"
@@ -17,11 +19,7 @@ var renameAndIndexCmd = &cobra.Command{
 }
 
 func renameAndIndexHandler(cmd *cobra.Command, args []string) {
-	dir := appConfig.EpisodeDirectory
-	if customEpisodeDirectory != "" {
-		dir = customEpisodeDirectory
-	}
-	HandleError(os.Chdir(dir))
+	HandleError(os.Chdir(appConfig.EpisodeDirectory))
 
 	interestingEntries := GetInterestingDirEntries()
 	if len(interestingEntries) == 0 {
@@ -42,10 +40,15 @@ func renameAndIndexHandler(cmd *cobra.Command, args []string) {
 		for _, episode := range renameableEpisodes {
 			LOG.Printf("> %s: %s", episode.Series, episode.CleanedFileName())
 
+			originalPath := episode.Path
 			HandleError(episode.Rename("."))
 			LOG.Printf("  [OK]\n")
 
 			callEpisodeHook(episode.CleanedFileName(), episode.Series)
+
+			payload := episodePayload(episode)
+			payload["original_path"] = originalPath
+			HandleError(emitEvent(hooks.EpisodeRenamed, payload))
 		}
 
 		callPostProcessingHook()
@@ -90,7 +93,11 @@ func HandleInterestingEpisodes(entries []string) []*renamer.Episode {
 
 		episode.RemoveTrashWords()
 		if !episode.HasValidEpisodeName() {
-			episode.SetDefaultEpisodeName()
+			if title, found := seriesIndex.LookupEpisodeTitle(episode); found {
+				episode.Name = title
+			} else {
+				episode.SetDefaultEpisodeName()
+			}
 		}
 
 		LOG.Printf("<<< %s\n", entryPath)
@@ -102,12 +109,20 @@ func HandleInterestingEpisodes(entries []string) []*renamer.Episode {
 		}
 
 		if addToIndex {
-			added, addedErr := seriesIndex.AddEpisode(episode)
-			if !added {
+			result, addedErr := seriesIndex.AddEpisode(episode)
+			if addedErr == index.ErrUnknownSeries {
+				result, addedErr = handleUnknownSeries(entryPath, episode, result, addedErr)
+			}
+			if verbose {
+				printMatchResult(LOG.Writer(), result)
+			}
+			if !result.Added {
 				LOG.Printf("!!! couldn't be added to the index: %s\n\n", addedErr)
 				continue
 			}
 			LOG.Printf("---> succesfully added to series index\n\n")
+			removePendingEpisode(entryPath)
+			HandleError(emitEvent(hooks.EpisodeIndexed, episodePayload(episode)))
 		}
 
 		renameableEpisodes = append(renameableEpisodes, episode)
@@ -5,12 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"github.com/pboehm/series/config"
+	"github.com/pboehm/series/hooks"
 	idx "github.com/pboehm/series/index"
 	str "github.com/pboehm/series/streams"
+	"github.com/pboehm/series/util"
 	"github.com/spf13/cobra"
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
 )
 
 var streamsCmdJsonOutput = false
@@ -27,9 +30,18 @@ var streamsUnknownSeriesCmd = &cobra.Command{
 	Use:   "unknown",
 	Short: "list all series which are unknown by the streaming site",
 	Run: func(cmd *cobra.Command, args []string) {
-		withIndexStreamsAndWatchedSeries(func(index *idx.SeriesIndex, streams *str.Streams, watched []str.WatchedSeries) {
+		withIndexStreamsAndWatchedSeries(func(index *idx.SeriesIndex, providers []str.Provider, watched []str.WatchedSeries, providerErrors map[str.Provider]error) {
+			// series of a provider which could not be listed would be
+			// reported as unknown
+			for _, err := range providerErrors {
+				HandleError(err)
+			}
+
 			existingSeries := map[string]idx.Series{}
 			for _, series := range index.SeriesList {
+				if series.GetStatus() == idx.StatusDropped {
+					continue
+				}
 				existingSeries[series.Name] = series
 			}
 
@@ -48,9 +60,9 @@ var streamsFetchLinksCmd = &cobra.Command{
 	Use:   "links",
 	Short: "fetch Links for unwatched episodes of series",
 	Run: func(cmd *cobra.Command, args []string) {
-		withIndexStreamsAndWatchedSeries(func(index *idx.SeriesIndex, streams *str.Streams, watched []str.WatchedSeries) {
-			linkSet := str.NewLinkSet(appConfig, streams, index)
-			linkSet.GrabLinksFor(watched)
+		withIndexStreamsAndWatchedSeries(func(index *idx.SeriesIndex, providers []str.Provider, watched []str.WatchedSeries, providerErrors map[str.Provider]error) {
+			linkSet := newLinkSet(index, providers, watched, providerErrors)
+			HandleError(emitNewEpisodes(linkSet))
 
 			if streamsCmdJsonOutput {
 				entries := linkSet.Entries()
@@ -68,7 +80,7 @@ var streamsFetchLinksCmd = &cobra.Command{
 							if i >= 2 {
 								break
 							}
-							fmt.Printf("  %s\t  [%s]\n", link.Link, link.Hoster)
+							fmt.Printf("  %s\t  [%s, %s]\n", link.Link, link.Hoster, link.Provider)
 						}
 					}
 				}
@@ -85,8 +97,16 @@ func markEpisodeAsWatched(index *idx.SeriesIndex, episodeId string) (*str.Identi
 		return nil, err
 	}
 
-	filename := fmt.Sprintf("S%02dE%02d - Episode %d.mov", id.Season, id.Episode, id.Episode)
+	title := strings.Replace(id.EpisodeName, "/", "-", -1)
+	if title == "" {
+		title = fmt.Sprintf("Episode %d", id.Episode)
+	}
+
+	filename := fmt.Sprintf("S%02dE%02d - %s.mov", id.Season, id.Episode, title)
 	_, err = index.AddEpisodeManually(id.Series, id.Language, id.Season, id.Episode, filename)
+	if err == nil && id.EpisodeName != "" {
+		err = index.SetEpisodeTitle(id.Series, id.Language, id.Season, id.Episode, id.EpisodeName)
+	}
 	return id, err
 }
 
@@ -106,6 +126,10 @@ var streamsMarkWatchedCmd = &cobra.Command{
 			seasonWithEpisode := fmt.Sprintf("S%02dE%02d", id.Season, id.Episode)
 			if err == nil {
 				LOG.Printf("Marking %s of %s [%s] as watched\n", seasonWithEpisode, id.Series, id.Language)
+				HandleError(emitEvent(hooks.EpisodeIndexed, hooks.Payload{
+					"series": id.Series, "season": id.Season, "episode": id.Episode,
+					"name": id.EpisodeName, "language": id.Language,
+				}))
 			} else {
 				LOG.Printf("Could not mark %s of %s [%s] as watched: %s\n", seasonWithEpisode, id.Series, id.Language, err)
 			}
@@ -139,28 +163,33 @@ var streamsRunActionCmd = &cobra.Command{
 			HandleError(errors.New(fmt.Sprintf("action '%s' not found", actionId)))
 		}
 
-		withIndexStreamsAndWatchedSeries(func(index *idx.SeriesIndex, streams *str.Streams, watched []str.WatchedSeries) {
+		withIndexStreamsAndWatchedSeries(func(index *idx.SeriesIndex, providers []str.Provider, watched []str.WatchedSeries, providerErrors map[str.Provider]error) {
 			identifier, linkId, err := str.LinkIdentifierFromString(linkIdString)
 			HandleError(err)
 
-			HandleError(runAction(os.Stderr, streams, action, identifier, linkId))
+			HandleError(runAction(os.Stderr, providers, action, identifier, linkId))
 		})
 	},
 }
 
-func runAction(output io.Writer, streams *str.Streams, action config.StreamAction, id *str.Identifier, linkId int) error {
+func runAction(output io.Writer, providers []str.Provider, action config.StreamAction, id *str.Identifier, linkId int) error {
 	var err error
 	var session, videoUrl string
 
-	if session, err = streams.Login(appConfig.StreamsAccountEmail, appConfig.StreamsAccountPassword); err != nil {
+	provider, ok := str.FindProvider(providers, id.ProviderName())
+	if !ok {
+		return errors.New(fmt.Sprintf("provider '%s' is not configured", id.ProviderName()))
+	}
+
+	if session, err = provider.Login(); err != nil {
 		return err
 	}
 
-	if videoUrl, err = streams.ResolveLink(linkId, session); err != nil {
+	if videoUrl, err = provider.ResolveLink(linkId, session); err != nil {
 		return err
 	}
 
-	return SystemV(action.Command, []string{
+	environ := []string{
 		fmt.Sprintf("SERIES_SERIES=%s", id.Series),
 		fmt.Sprintf("SERIES_SERIES_SLUG=%s", id.SeriesSlug),
 		fmt.Sprintf("SERIES_SERIES_ID=%d", id.SeriesId),
@@ -169,13 +198,23 @@ func runAction(output io.Writer, streams *str.Streams, action config.StreamActio
 		fmt.Sprintf("SERIES_EPISODE=%d", id.Episode),
 		fmt.Sprintf("SERIES_EPISODE_NAME=%s", id.EpisodeName),
 		fmt.Sprintf("SERIES_FILENAME=S%02dE%02d - %s.mov", id.Season, id.Episode, id.EpisodeName),
+		fmt.Sprintf("SERIES_PROVIDER=%s", provider.Name()),
 		fmt.Sprintf("SERIES_LINK_ID=%d", linkId),
-		fmt.Sprintf("SERIES_REDIRECT_URL=%s", streams.LinkUrl(linkId)),
 		fmt.Sprintf("SERIES_VIDEO_URL=%s", videoUrl),
-		fmt.Sprintf("SERIES_SESSION=%s", session),
-		fmt.Sprintf("SERIES_API_TOKEN=%s", streams.Config.StreamsAPIToken),
-		fmt.Sprintf("SERIES_CONFIG_FILE=%s", configFile),
-	}, output, output)
+	}
+	environ = append(environ, configEnviron()...)
+
+	// the redirect URL contains the API token as well
+	if action.PassSecrets {
+		environ = append(environ,
+			fmt.Sprintf("SERIES_REDIRECT_URL=%s", provider.LinkUrl(linkId)),
+			fmt.Sprintf("SERIES_SESSION=%s", session),
+			fmt.Sprintf("SERIES_API_TOKEN=%s", streamsProviderConfig(provider.Name()).APIToken),
+		)
+		environ = append(environ, config.SecretEnviron(os.Environ())...)
+	}
+
+	return SystemV(action.Command, environ, output, output)
 }
 
 var streamsServerOptionListen string
@@ -199,15 +238,26 @@ var streamsServerCmd = &cobra.Command{
 		}
 
 		var currentLinkSet *str.LinkSet
-		var currentStreams *str.Streams
+		var currentProviders []str.Provider
 
+		// errors are shown in the /api/links response instead of stopping the
+		// server, as they may be gone on the next refresh
 		loadLinkSet := func() {
-			withIndexStreamsAndWatchedSeries(func(index *idx.SeriesIndex, streams *str.Streams, watched []str.WatchedSeries) {
-				linkSet := str.NewLinkSet(appConfig, streams, index)
-				linkSet.GrabLinksFor(watched)
+			providers, watched, providerErrors, err := loadStreamsAndWatchedSeries()
+			if err != nil {
+				LOG.Printf("!!! Loading the links failed: %s\n", err)
+				linkSet := str.NewLinkSet(appConfig, nil, nil)
+				linkSet.AddError(err)
 				currentLinkSet = linkSet
-				currentStreams = streams
-			})
+				return
+			}
+
+			linkSet := newLinkSet(seriesIndex, providers, watched, providerErrors)
+			if err := emitNewEpisodes(linkSet); err != nil {
+				LOG.Printf("!!! %s\n", err)
+			}
+			currentLinkSet = linkSet
+			currentProviders = providers
 		}
 
 		go loadLinkSet()
@@ -220,11 +270,14 @@ var streamsServerCmd = &cobra.Command{
 			LinkSet: func() *str.LinkSet {
 				return currentLinkSet
 			},
-			MarkWatched: func(episodeIds []string) ([]string, []string) {
+			MarkWatched: func(episodeIds []string) ([]string, []string, error) {
 				var successes, failures []string
 
 				callPreProcessingHook()
-				loadIndex()
+				if err := readIndex(); err != nil {
+					LOG.Printf("!!! Loading the index failed: %s\n", err)
+					return nil, episodeIds, err
+				}
 
 				for _, episodeId := range episodeIds {
 					_, err := markEpisodeAsWatched(seriesIndex, episodeId)
@@ -235,22 +288,29 @@ var streamsServerCmd = &cobra.Command{
 					}
 				}
 
-				writeIndex()
+				if err := saveIndex(); err != nil {
+					// the index has been written when a hook aborted
+					if _, aborted := err.(*hooks.AbortError); !aborted {
+						LOG.Printf("!!! Writing the index failed: %s\n", err)
+						return nil, episodeIds, err
+					}
+					LOG.Printf("!!! %s\n", err)
+				}
 				callPostProcessingHook()
 
 				loadLinkSet()
 
-				return successes, failures
+				return successes, failures, nil
 			},
 			ExecuteLinkAction: func(action config.StreamAction, identifier *str.Identifier, i int) *str.Job {
 				return str.NewJob(func(output io.Writer) error {
 					multiWriter := io.MultiWriter(output, os.Stderr)
 
-					if currentStreams == nil {
+					if currentProviders == nil {
 						return errors.New("streams not initialized")
 					}
 
-					return runAction(multiWriter, currentStreams, action, identifier, i)
+					return runAction(multiWriter, currentProviders, action, identifier, i)
 				})
 			},
 			ExecuteGlobalAction: func(action config.StreamAction) *str.Job {
@@ -259,19 +319,25 @@ var streamsServerCmd = &cobra.Command{
 					return SystemV(action.Command, []string{}, multiWriter, multiWriter)
 				})
 			},
-			ResolveLink: func(linkId int) (string, error) {
+			HookExecutions: hookSummary.Executions,
+			ResolveLink: func(providerName string, linkId int) (string, error) {
 				var session, videoUrl string
 				var err error
 
-				if currentStreams == nil {
+				if currentProviders == nil {
 					return "", errors.New("streams not initialized")
 				}
 
-				if session, err = currentStreams.Login(appConfig.StreamsAccountEmail, appConfig.StreamsAccountPassword); err != nil {
+				provider, ok := str.FindProvider(currentProviders, providerName)
+				if !ok {
+					return "", errors.New(fmt.Sprintf("provider '%s' is not configured", providerName))
+				}
+
+				if session, err = provider.Login(); err != nil {
 					return "", err
 				}
 
-				if videoUrl, err = currentStreams.ResolveLink(linkId, session); err != nil {
+				if videoUrl, err = provider.ResolveLink(linkId, session); err != nil {
 					return "", err
 				}
 
@@ -282,49 +348,195 @@ var streamsServerCmd = &cobra.Command{
 	},
 }
 
-func withIndexStreamsAndWatchedSeries(handler func(*idx.SeriesIndex, *str.Streams, []str.WatchedSeries)) {
-	if appConfig.StreamsAPIToken == "" {
-		HandleError(errors.New(fmt.Sprintf("`StreamsAPIToken` not configured in %s", configFile)))
+// emitNewEpisodes emits the streams.new_episodes event for the entries of the
+// link set which have not been seen before. The ids of the current entries
+// are remembered in `SeenLinksFile`. The error of an aborting handler is
+// returned.
+func emitNewEpisodes(linkSet *str.LinkSet) error {
+	seen := map[string]bool{}
+	if appConfig.SeenLinksFile != "" && util.PathExists(appConfig.SeenLinksFile) {
+		var ids []string
+		content, err := ioutil.ReadFile(appConfig.SeenLinksFile)
+		if err == nil {
+			err = json.Unmarshal(content, &ids)
+		}
+		if err != nil {
+			LOG.Printf("!!! Reading %s wasn't possible: %s\n", appConfig.SeenLinksFile, err)
+		}
+
+		for _, id := range ids {
+			seen[id] = true
+		}
 	}
 
-	if appConfig.StreamsAccountEmail == "" || appConfig.StreamsAccountPassword == "" {
-		HandleError(errors.New(fmt.Sprintf(
-			"`StreamsAccountEmail` or `StreamsAccountPassword` is not configured in %s", configFile)))
+	ids := []string{}
+	newEntries := []*str.LinkSetEntry{}
+	for _, entry := range linkSet.Entries() {
+		ids = append(ids, entry.Id)
+		if !seen[entry.Id] {
+			newEntries = append(newEntries, entry)
+		}
 	}
 
-	callPreProcessingHook()
-	loadIndex()
+	// links of failed series are missing, so the ids seen before are kept
+	// to not report their episodes again on the next run
+	if len(linkSet.Errors()) > 0 {
+		current := map[string]bool{}
+		for _, id := range ids {
+			current[id] = true
+		}
+		for id := range seen {
+			if !current[id] {
+				ids = append(ids, id)
+			}
+		}
+	}
 
-	streams := str.NewStreams(appConfig)
-	availableSeries := streams.AvailableSeries()
+	if appConfig.SeenLinksFile != "" {
+		content, err := json.Marshal(ids)
+		if err == nil {
+			err = ioutil.WriteFile(appConfig.SeenLinksFile, content, 0644)
+		}
+		if err != nil {
+			LOG.Printf("!!! Writing %s wasn't possible: %s\n", appConfig.SeenLinksFile, err)
+		}
+	}
+
+	if len(newEntries) > 0 {
+		return emitEvent(hooks.StreamsNewEpisodes, hooks.Payload{"count": len(newEntries), "episodes": newEntries})
+	}
+	return nil
+}
+
+// streamsProviderConfigs returns the provider configured by the Streams*
+// settings, when there is an API token, followed by the StreamsProviders
+func streamsProviderConfigs() []config.StreamsProvider {
+	var providers []config.StreamsProvider
+
+	if appConfig.StreamsAPIToken != "" {
+		providers = append(providers, config.StreamsProvider{
+			Name:            config.DefaultStreamsProvider,
+			BaseURL:         str.DefaultBaseURL,
+			APIToken:        appConfig.StreamsAPIToken,
+			AccountEmail:    appConfig.StreamsAccountEmail,
+			AccountPassword: appConfig.StreamsAccountPassword,
+		})
+	}
+
+	return append(providers, appConfig.StreamsProviders...)
+}
+
+func streamsProviderConfig(name string) config.StreamsProvider {
+	for _, provider := range streamsProviderConfigs() {
+		if provider.Name == name {
+			return provider
+		}
+	}
+	return config.StreamsProvider{}
+}
+
+// withIndexStreamsAndWatchedSeries calls the handler with the series of all
+// providers which are watched according to the index. Providers whose series
+// could not be listed are passed with the error.
+func withIndexStreamsAndWatchedSeries(handler func(*idx.SeriesIndex, []str.Provider, []str.WatchedSeries, map[str.Provider]error)) {
+	providers, watched, providerErrors, err := loadStreamsAndWatchedSeries()
+	HandleError(err)
+
+	handler(seriesIndex, providers, watched, providerErrors)
+}
+
+// loadStreamsAndWatchedSeries loads the index and lists the watched series of
+// all providers. Errors preventing this for all providers are returned, like
+// missing credentials, those of single providers are returned in the map.
+func loadStreamsAndWatchedSeries() ([]str.Provider, []str.WatchedSeries, map[str.Provider]error, error) {
+	// secrets are only resolved when needed, as their commands may ask for a
+	// passphrase
+	if err := appConfig.ResolveSecrets(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	providerConfigs := streamsProviderConfigs()
+	if len(providerConfigs) == 0 {
+		return nil, nil, nil, errors.New(fmt.Sprintf(
+			"`StreamsAPIToken`, `StreamsAPITokenFile` or `StreamsAPITokenCommand` not configured in %s",
+			configFile))
+	}
+
+	var providers []str.Provider
+	for _, provider := range providerConfigs {
+		if provider.APIToken == "" || provider.AccountEmail == "" || provider.AccountPassword == "" {
+			return nil, nil, nil, errors.New(fmt.Sprintf(
+				"the API token, account email or account password of provider '%s' is not configured in %s",
+				provider.Name, configFile))
+		}
+
+		providers = append(providers, str.NewStreams(provider))
+	}
+
+	callPreProcessingHook()
+	if err := readIndex(); err != nil {
+		return nil, nil, nil, err
+	}
 
 	var watched []str.WatchedSeries
-	for _, series := range availableSeries {
-		nameInIndex := seriesIndex.SeriesNameInIndex(series.Name)
-		if nameInIndex != "" {
-			languages := seriesIndex.SeriesLanguages(nameInIndex)
-			watched = append(watched, str.WatchedSeries{
-				Series:            series,
-				SeriesNameInIndex: nameInIndex,
-				SeriesLanguages:   mapLanguagesToIds(languages),
-			})
+	providerErrors := map[str.Provider]error{}
+	for _, provider := range providers {
+		availableSeries, err := provider.AvailableSeries()
+		if err != nil {
+			LOG.Printf("!!! Listing the series of provider '%s' failed: %s\n", provider.Name(), err)
+			providerErrors[provider] = err
+			continue
+		}
+
+		for _, series := range availableSeries {
+			nameInIndex := seriesIndex.SeriesNameInIndex(series.Name)
+			if nameInIndex != "" {
+				status := seriesIndex.SeriesStatus(nameInIndex)
+				if status == idx.StatusDropped {
+					continue
+				}
+
+				languages := seriesIndex.SeriesLanguages(nameInIndex)
+				watched = append(watched, str.WatchedSeries{
+					Provider:          provider,
+					Series:            series,
+					SeriesNameInIndex: nameInIndex,
+					SeriesLanguages:   mapLanguagesToIds(provider, languages),
+					Status:            status,
+				})
+			}
 		}
 	}
 
-	handler(seriesIndex, streams, watched)
+	return providers, watched, providerErrors, nil
 }
 
-func mapLanguagesToIds(languages []string) map[string]int {
+// newLinkSet fetches the links for the watched series, the failures are
+// logged and kept in the link set
+func newLinkSet(index *idx.SeriesIndex, providers []str.Provider, watched []str.WatchedSeries,
+	providerErrors map[str.Provider]error) *str.LinkSet {
+
+	linkSet := str.NewLinkSet(appConfig, providers, index)
+	for provider, err := range providerErrors {
+		linkSet.AddProviderError(provider, err)
+	}
+
+	linkSet.GrabLinksFor(watched)
+	for _, err := range linkSet.Errors() {
+		if err.Series != "" {
+			LOG.Printf("!!! Fetching links for %s from provider '%s' failed: %s\n", err.Series, err.Provider, err.Error)
+		}
+	}
+
+	return linkSet
+}
+
+func mapLanguagesToIds(provider str.Provider, languages []string) map[string]int {
 	mapped := map[string]int{}
 
 	for _, language := range languages {
-		switch language {
-		case "de":
-			mapped[language] = 1
-		case "en":
-			mapped[language] = 2
-		default:
-			continue
+		if id, ok := provider.LanguageId(language); ok {
+			mapped[language] = id
 		}
 	}
 
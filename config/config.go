@@ -1,7 +1,9 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"github.com/pboehm/series/hooks"
 	"github.com/pboehm/series/util"
 	"io/ioutil"
 	"os"
@@ -12,55 +14,135 @@ type StreamAction struct {
 	Id      string
 	Title   string
 	Command string
+
+	// PassSecrets passes the session and the API token to the command
+	PassSecrets bool `json:",omitempty"`
+}
+
+// StreamsProvider configures an additional streaming site whose links are
+// merged with the links of the site configured by the Streams* settings. The
+// site has to offer the same API.
+type StreamsProvider struct {
+	Name    string
+	BaseURL string
+
+	APIToken, APITokenFile, APITokenCommand                      string `json:",omitempty"`
+	AccountEmail                                                 string `json:",omitempty"`
+	AccountPassword, AccountPasswordFile, AccountPasswordCommand string `json:",omitempty"`
+
+	// Languages maps languages to the ids used by the site, German and
+	// English are mapped to 1 and 2 when empty
+	Languages map[string]int `json:",omitempty"`
+}
+
+// ScriptExtractor configures a script asking for series names. In the config
+// file it is either the path of the script or an object with the path and a
+// timeout in seconds.
+type ScriptExtractor struct {
+	Path    string
+	Timeout int `json:",omitempty"`
+}
+
+func (s *ScriptExtractor) UnmarshalJSON(data []byte) error {
+	var scriptPath string
+	if err := json.Unmarshal(data, &scriptPath); err == nil {
+		*s = ScriptExtractor{Path: scriptPath}
+		return nil
+	}
+
+	type plain ScriptExtractor
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+type SeriesNameRule struct {
+	Pattern string
+	Series  string
 }
 
 type Config struct {
 	IndexFile, PreProcessingHook, PostProcessingHook, EpisodeHook string
-	EpisodeDirectory                                              string
-	ScriptExtractors                                              []string
+	EpisodeDirectory, LibraryDirectory, MetadataDirectory         string
+	IndexBackupDirectory                                          string
+	IndexBackupCount, IndexBackupDays                             int
+	ScriptExtractors                                              []ScriptExtractor
+	SeriesNameRules                                               []SeriesNameRule
+	SeriesNameMappingFile                                         string
+	ExtractorOrder                                                []string
+	UnknownSeriesPolicy, UnknownSeriesHook, PendingFile           string
+	Hooks                                                         map[string][]hooks.Handler
+	HookTimeout, HookRetries                                      int
+	Webhooks                                                      []hooks.Webhook
+	SeenLinksFile                                                 string
 	StreamsAPIToken                                               string
+	StreamsAPITokenFile, StreamsAPITokenCommand                   string
 	StreamsAccountEmail                                           string
 	StreamsAccountPassword                                        string
+	StreamsAccountPasswordFile, StreamsAccountPasswordCommand     string
+	StreamsProviders                                              []StreamsProvider
 	StreamsGlobalActions                                          []StreamAction
 	StreamsLinkActions                                            []StreamAction
+	RewriteConfig                                                 bool
+
+	// Profiles contain values which are decoded over the other values when
+	// the profile is selected
+	Profiles map[string]json.RawMessage `json:",omitempty"`
 }
 
-func GetConfig(configFile string, standard Config) Config {
+// GetConfig reads the config file over the standard config. A missing file is
+// created with the standard config. Unless RewriteConfig is disabled, the file
+// is written again afterwards, so that it contains newly added fields.
+func GetConfig(configFile string, standard Config) (Config, error) {
 
 	if !util.PathExists(configFile) {
 		configDir := path.Dir(configFile)
 
-		dirErr := os.MkdirAll(configDir, 0755)
-		if dirErr != nil {
-			panic(dirErr)
+		if err := os.MkdirAll(configDir, 0755); err != nil {
+			return standard, err
 		}
 
-		writeMarshaledDataToFile(configFile, standard)
+		if err := WriteConfig(configFile, standard); err != nil {
+			return standard, err
+		}
 	}
 
-	content, readErr := ioutil.ReadFile(configFile)
-	if readErr != nil {
-		panic(readErr)
+	config, err := ReadConfig(configFile, standard)
+	if err != nil {
+		return standard, err
 	}
 
-	unmarshalErr := json.Unmarshal(content, &standard)
-	if unmarshalErr != nil {
-		panic(unmarshalErr)
+	if config.RewriteConfig {
+		if err = WriteConfig(configFile, config); err != nil {
+			return config, err
+		}
 	}
 
-	writeMarshaledDataToFile(configFile, standard)
-
-	return standard
+	return config, nil
 }
 
-func writeMarshaledDataToFile(file string, config Config) {
-	marshaled, marshalErr := json.MarshalIndent(config, "", "  ")
-	if marshalErr != nil {
-		panic(marshalErr)
+// ReadConfig decodes the config file strictly over the standard config, so
+// that unknown keys are reported instead of being ignored
+func ReadConfig(configFile string, standard Config) (Config, error) {
+	content, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return standard, err
 	}
 
-	writeErr := ioutil.WriteFile(file, marshaled, 0644)
-	if writeErr != nil {
-		panic(writeErr)
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+
+	if err = decoder.Decode(&standard); err != nil {
+		return standard, describeDecodeError(configFile, content, err)
 	}
+
+	return standard, nil
+}
+
+func WriteConfig(file string, config Config) error {
+	marshaled, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// the config may contain secrets, existing files keep their mode
+	return ioutil.WriteFile(file, append(marshaled, '\n'), 0600)
 }
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"encoding/json"
 	"github.com/pboehm/series/util"
+	"io/ioutil"
 	. "launchpad.net/gocheck"
+	"os"
 	"path"
 	"testing"
 )
@@ -21,10 +24,15 @@ func (s *MySuite) SetUpTest(c *C) {
 	s.configFile = path.Join(s.dir, ".series/config.json")
 }
 
+func createConfig(file string, content string) {
+	_ = ioutil.WriteFile(file, []byte(content), 0644)
+}
+
 func (s *MySuite) TestConfigParsingWhenNoConfigExists(c *C) {
 	standard := Config{}
-	config := GetConfig(s.configFile, standard)
+	config, err := GetConfig(s.configFile, standard)
 
+	c.Assert(err, IsNil)
 	c.Assert(config, DeepEquals, standard)
 	c.Assert(util.PathExists(s.configFile), Equals, true)
 }
@@ -32,10 +40,407 @@ func (s *MySuite) TestConfigParsingWhenNoConfigExists(c *C) {
 func (s *MySuite) TestConfigParsingWithChanges(c *C) {
 	// create config which seems to be changed by the user
 	old := Config{IndexFile: "/not/existing/file.json"}
-	_ = GetConfig(s.configFile, old)
+	_, _ = GetConfig(s.configFile, old)
 	c.Assert(util.PathExists(s.configFile), Equals, true)
 
 	standard := Config{IndexFile: "/other/non/existing/file"}
-	config := GetConfig(s.configFile, standard)
+	config, _ := GetConfig(s.configFile, standard)
 	c.Assert(config.IndexFile, Equals, "/not/existing/file.json")
 }
+
+func (s *MySuite) TestScriptExtractorsAsPathOrObject(c *C) {
+	_, _ = GetConfig(s.configFile, Config{})
+	createConfig(s.configFile, `{"ScriptExtractors": ["/bin/names", {"Path": "/bin/slow", "Timeout": 30}]}`)
+
+	config, err := GetConfig(s.configFile, Config{RewriteConfig: true})
+	c.Assert(err, IsNil)
+	c.Assert(config.ScriptExtractors, DeepEquals, []ScriptExtractor{
+		{Path: "/bin/names"},
+		{Path: "/bin/slow", Timeout: 30},
+	})
+
+	// the rewritten config file has to be readable again
+	config, err = GetConfig(s.configFile, Config{})
+	c.Assert(err, IsNil)
+	c.Assert(config.ScriptExtractors, HasLen, 2)
+}
+
+func (s *MySuite) TestConfigIsNotRewrittenWhenDisabled(c *C) {
+	_, _ = GetConfig(s.configFile, Config{})
+	createConfig(s.configFile, `{"IndexFile": "/index.xml"}`)
+
+	_, err := GetConfig(s.configFile, Config{EpisodeDirectory: "/downloads"})
+	c.Assert(err, IsNil)
+
+	content, _ := ioutil.ReadFile(s.configFile)
+	c.Assert(string(content), Equals, `{"IndexFile": "/index.xml"}`)
+}
+
+func (s *MySuite) TestUnknownKeys(c *C) {
+	_, _ = GetConfig(s.configFile, Config{})
+	createConfig(s.configFile, "{\n  \"IndexFiel\": \"/index.xml\"\n}")
+
+	_, err := GetConfig(s.configFile, Config{})
+	c.Assert(err, ErrorMatches, `.*config.json: unknown key "IndexFiel", did you mean "IndexFile"\?`)
+
+	createConfig(s.configFile, `{"SomethingElse": true}`)
+	_, err = GetConfig(s.configFile, Config{})
+	c.Assert(err, ErrorMatches, `.*config.json: unknown key "SomethingElse"`)
+}
+
+func (s *MySuite) TestSyntaxAndTypeErrors(c *C) {
+	_, _ = GetConfig(s.configFile, Config{})
+
+	createConfig(s.configFile, "{\n  \"IndexFile\": \"/index.xml\",\n}")
+	_, err := GetConfig(s.configFile, Config{})
+	c.Assert(err, ErrorMatches, `.*config.json:3:1: syntax error: .*`)
+
+	createConfig(s.configFile, "{\n  \"IndexBackupCount\": \"ten\"\n}")
+	_, err = GetConfig(s.configFile, Config{})
+	c.Assert(err, ErrorMatches, `.*config.json:2:\d+: IndexBackupCount has to be of type int, not a JSON string`)
+}
+
+func (s *MySuite) TestValidate(c *C) {
+	valid := Config{
+		IndexFile:           path.Join(s.dir, "index.xml"),
+		EpisodeDirectory:    s.dir,
+		PostProcessingHook:  "cd /tmp && git push",
+		ExtractorOrder:      []string{"filesystem", "script"},
+		UnknownSeriesPolicy: UnknownSeriesQueue,
+	}
+	c.Assert(Validate(valid), IsNil)
+
+	invalid := valid
+	invalid.EpisodeHook = "/not/existing/hook.sh"
+	invalid.LibraryDirectory = "/not/existing/library"
+	invalid.ExtractorOrder = []string{"filesystem", "magic"}
+	invalid.StreamsLinkActions = []StreamAction{{Id: "download"}, {Id: "download"}}
+
+	err := Validate(invalid)
+	c.Assert(err, NotNil)
+	c.Assert(err.(*ValidationError).Problems, DeepEquals, []string{
+		"EpisodeHook: '/not/existing/hook.sh' does not exist",
+		"LibraryDirectory: '/not/existing/library' is not a directory",
+		"ExtractorOrder: unknown extractor 'magic', use one of: filesystem, regex, mapping, script",
+		"StreamsLinkActions: Id 'download' is used by more than one action",
+	})
+}
+
+func (s *MySuite) TestGetAndSetField(c *C) {
+	config := Config{}
+
+	c.Assert(SetField(&config, "unknownseriespolicy", "queue"), IsNil)
+	c.Assert(SetField(&config, "IndexBackupCount", "20"), IsNil)
+	c.Assert(SetField(&config, "ExtractorOrder", `["script"]`), IsNil)
+	c.Assert(SetField(&config, "IndexBackupDays", "many"), ErrorMatches, "IndexBackupDays has to be of type int: .*")
+	c.Assert(SetField(&config, "IndexFiles", "/x"), ErrorMatches, `unknown key "IndexFiles", did you mean "IndexFile"\?`)
+
+	c.Assert(config.UnknownSeriesPolicy, Equals, UnknownSeriesQueue)
+	c.Assert(config.IndexBackupCount, Equals, 20)
+	c.Assert(config.ExtractorOrder, DeepEquals, []string{"script"})
+
+	// strings are never decoded as JSON
+	c.Assert(SetField(&config, "StreamsAccountPassword", `"quoted"`), IsNil)
+	c.Assert(config.StreamsAccountPassword, Equals, `"quoted"`)
+	c.Assert(SetField(&config, "IndexFile", `\u0041`), IsNil)
+	c.Assert(config.IndexFile, Equals, `\u0041`)
+
+	value, err := GetField(config, "IndexBackupCount")
+	c.Assert(err, IsNil)
+	c.Assert(value, Equals, 20)
+}
+
+func (s *MySuite) TestEnvName(c *C) {
+	c.Assert(EnvName("IndexFile"), Equals, "SERIES_INDEX_FILE")
+	c.Assert(EnvName("StreamsAPIToken"), Equals, "SERIES_STREAMS_API_TOKEN")
+	c.Assert(EnvName("RewriteConfig"), Equals, "SERIES_REWRITE_CONFIG")
+}
+
+func (s *MySuite) TestLoadConfigPrecedence(c *C) {
+	standard := Config{IndexFile: "/default/index.xml", EpisodeDirectory: "/default/episodes"}
+	_, _ = GetConfig(s.configFile, Config{})
+	createConfig(s.configFile, `{"IndexFile": "/file/index.xml", "IndexBackupCount": 3, "HookTimeout": 10}`)
+
+	environ := []string{"SERIES_INDEX_BACKUP_COUNT=5", "SERIES_HOOK_TIMEOUT=20", "PATH=/bin"}
+	config, sources, err := LoadConfig(s.configFile, standard, "", environ, []string{"hooktimeout=30"})
+	c.Assert(err, IsNil)
+
+	c.Assert(config.EpisodeDirectory, Equals, "/default/episodes")
+	c.Assert(sources["EpisodeDirectory"], Equals, SourceDefault)
+	c.Assert(config.IndexFile, Equals, "/file/index.xml")
+	c.Assert(sources["IndexFile"], Equals, SourceFile)
+	c.Assert(config.IndexBackupCount, Equals, 5)
+	c.Assert(sources["IndexBackupCount"], Equals, SourceEnv)
+	c.Assert(config.HookTimeout, Equals, 30)
+	c.Assert(sources["HookTimeout"], Equals, SourceFlag)
+
+	// overrides are not written to the config file
+	fileConfig, err := ReadConfig(s.configFile, Config{})
+	c.Assert(err, IsNil)
+	c.Assert(fileConfig.HookTimeout, Equals, 10)
+}
+
+func (s *MySuite) TestLoadConfigInvalidOverrides(c *C) {
+	_, _, err := LoadConfig(s.configFile, Config{}, "", []string{"SERIES_HOOK_TIMEOUT=soon"}, nil)
+	c.Assert(err, ErrorMatches, "SERIES_HOOK_TIMEOUT: HookTimeout has to be of type int.*")
+
+	_, _, err = LoadConfig(s.configFile, Config{}, "", nil, []string{"HookTimeout"})
+	c.Assert(err, ErrorMatches, "override 'HookTimeout' has not the format key=value")
+
+	_, _, err = LoadConfig(s.configFile, Config{}, "", nil, []string{"IndexFiel=/tmp/index.xml"})
+	c.Assert(err, ErrorMatches, `--set IndexFiel=/tmp/index.xml: unknown key "IndexFiel", did you mean "IndexFile"\?`)
+}
+
+func (s *MySuite) TestReadSecretFile(c *C) {
+	secretFile := path.Join(s.dir, "token")
+	_ = ioutil.WriteFile(secretFile, []byte("s3cr3t\n"), 0644)
+
+	_, err := ReadSecretFile(secretFile)
+	c.Assert(err, ErrorMatches, ".*token is accessible by other users \\(mode 0644\\).*")
+
+	_ = os.Chmod(secretFile, 0600)
+	secret, err := ReadSecretFile(secretFile)
+	c.Assert(err, IsNil)
+	c.Assert(secret, Equals, "s3cr3t")
+}
+
+func (s *MySuite) TestResolveSecrets(c *C) {
+	secretFile := path.Join(s.dir, "token")
+	_ = ioutil.WriteFile(secretFile, []byte("from-file"), 0600)
+
+	config := Config{
+		StreamsAPITokenFile:           secretFile,
+		StreamsAccountPasswordCommand: "printf 'from-command\\nlogin: me\\n'",
+	}
+	c.Assert(config.ResolveSecrets(), IsNil)
+	c.Assert(config.StreamsAPIToken, Equals, "from-file")
+	c.Assert(config.StreamsAccountPassword, Equals, "from-command")
+
+	// values given directly, e.g. by the environment, take precedence
+	config = Config{StreamsAPIToken: "direct", StreamsAPITokenCommand: "false"}
+	c.Assert(config.ResolveSecrets(), IsNil)
+	c.Assert(config.StreamsAPIToken, Equals, "direct")
+
+	config = Config{StreamsAccountPasswordCommand: "false"}
+	c.Assert(config.ResolveSecrets(), ErrorMatches, "StreamsAccountPassword: command 'false' failed: .*")
+}
+
+func (s *MySuite) TestValidateSecrets(c *C) {
+	secretFile := path.Join(s.dir, "token")
+	_ = ioutil.WriteFile(secretFile, []byte("token"), 0640)
+
+	missing := path.Join(s.dir, "pass")
+	err := Validate(Config{StreamsAPITokenFile: secretFile, StreamsAPITokenCommand: missing + " show streams"})
+	c.Assert(err, NotNil)
+	c.Assert(err.(*ValidationError).Problems, DeepEquals, []string{
+		"StreamsAPIToken: only one of StreamsAPITokenFile and StreamsAPITokenCommand can be set",
+		"StreamsAPITokenFile: " + secretFile + " is accessible by other users (mode 0640), run: chmod 600 " + secretFile,
+		"StreamsAPITokenCommand: '" + missing + "' does not exist",
+	})
+}
+
+func (s *MySuite) TestLoadConfigWithProfile(c *C) {
+	_, _ = GetConfig(s.configFile, Config{})
+	createConfig(s.configFile, `{
+		"IndexFile": "/base/index.xml",
+		"EpisodeDirectory": "/base/episodes",
+		"HookRetries": 1,
+		"Profiles": {
+			"de": {"IndexFile": "/de/index.xml", "EpisodeDirectory": "/de/episodes"},
+			"en": {"indexfile": "/en/index.xml"}
+		}
+	}`)
+
+	config, sources, err := LoadConfig(s.configFile, Config{}, "de", []string{"SERIES_EPISODE_DIRECTORY=/env"}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(config.IndexFile, Equals, "/de/index.xml")
+	c.Assert(sources["IndexFile"], Equals, SourceProfile)
+	c.Assert(config.EpisodeDirectory, Equals, "/env")
+	c.Assert(sources["EpisodeDirectory"], Equals, SourceEnv)
+	c.Assert(config.HookRetries, Equals, 1)
+	c.Assert(sources["HookRetries"], Equals, SourceFile)
+
+	config, _, err = LoadConfig(s.configFile, Config{}, "en", nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(config.IndexFile, Equals, "/en/index.xml")
+	c.Assert(config.EpisodeDirectory, Equals, "/base/episodes")
+
+	_, _, err = LoadConfig(s.configFile, Config{}, "fr", nil, nil)
+	c.Assert(err, ErrorMatches, "profile 'fr' does not exist, available profiles: de, en")
+}
+
+func (s *MySuite) TestInvalidProfiles(c *C) {
+	config := Config{Profiles: map[string]json.RawMessage{
+		"typo":   json.RawMessage(`{"IndexFiel": "/index.xml"}`),
+		"nested": json.RawMessage(`{"Profiles": {}}`),
+		"type":   json.RawMessage(`{"HookTimeout": "long"}`),
+	}}
+
+	_, _, err := ApplyProfile(config, "typo")
+	c.Assert(err, ErrorMatches, `profile 'typo': unknown key "IndexFiel", did you mean "IndexFile"\?`)
+	_, _, err = ApplyProfile(config, "nested")
+	c.Assert(err, ErrorMatches, "profile 'nested': profiles can not be nested")
+	_, _, err = ApplyProfile(config, "type")
+	c.Assert(err, ErrorMatches, "profile 'type':1:23: HookTimeout has to be of type int, not a JSON string")
+}
+
+func (s *MySuite) TestSetProfileField(c *C) {
+	config := Config{IndexFile: "/base/index.xml", Profiles: map[string]json.RawMessage{
+		"de": json.RawMessage(`{"indexfile": "/de/index.xml", "HookRetries": 2}`),
+	}}
+
+	c.Assert(SetProfileField(&config, "de", "IndexFile", "/other/index.xml"), IsNil)
+	c.Assert(SetProfileField(&config, "de", "HookTimeout", "10"), IsNil)
+	c.Assert(config.IndexFile, Equals, "/base/index.xml")
+
+	effective, fields, err := ApplyProfile(config, "de")
+	c.Assert(err, IsNil)
+	c.Assert(fields, DeepEquals, []string{"HookRetries", "HookTimeout", "IndexFile"})
+	c.Assert(effective.IndexFile, Equals, "/other/index.xml")
+	c.Assert(effective.HookTimeout, Equals, 10)
+}
+
+func (s *MySuite) TestLegacyMigration(c *C) {
+	legacy := path.Join(s.dir, ".series")
+	_ = os.MkdirAll(path.Join(legacy, "backups"), 0755)
+	createConfig(path.Join(legacy, "config.json"), `{"IndexFile": "`+legacy+`/index.xml",
+		"IndexBackupDirectory": "`+legacy+`/backups", "EpisodeHook": "`+legacy+`/hook.sh --index `+legacy+`/index.xml"}`)
+	createConfig(path.Join(legacy, "index.xml"), "<seriesindex></seriesindex>")
+	createConfig(path.Join(legacy, "hook.sh"), "#!/bin/sh")
+
+	migration := LegacyMigration{
+		Directory:  legacy,
+		ConfigFile: path.Join(s.dir, "config/series/config.json"),
+		Targets: map[string]string{
+			"config.json": path.Join(s.dir, "config/series/config.json"),
+			"index.xml":   path.Join(s.dir, "data/series/index.xml"),
+			"backups":     path.Join(s.dir, "data/series/backups"),
+		},
+		DefaultDirectory: path.Join(s.dir, "config/series"),
+	}
+	c.Assert(migration.Needed(), Equals, true)
+
+	moves, err := migration.Run()
+	c.Assert(err, IsNil)
+	c.Assert(moves, HasLen, 4)
+	c.Assert(util.PathExists(legacy), Equals, false)
+	c.Assert(util.IsFile(path.Join(s.dir, "config/series/hook.sh")), Equals, true)
+	c.Assert(util.IsDirectory(path.Join(s.dir, "data/series/backups")), Equals, true)
+	c.Assert(migration.Needed(), Equals, false)
+
+	config, err := ReadConfig(migration.ConfigFile, Config{})
+	c.Assert(err, IsNil)
+	c.Assert(config.IndexFile, Equals, path.Join(s.dir, "data/series/index.xml"))
+	c.Assert(config.IndexBackupDirectory, Equals, path.Join(s.dir, "data/series/backups"))
+	c.Assert(config.EpisodeHook, Equals, path.Join(s.dir, "config/series/hook.sh")+
+		" --index "+path.Join(s.dir, "data/series/index.xml"))
+}
+
+func (s *MySuite) TestLegacyMigrationWithExistingTarget(c *C) {
+	legacy := path.Join(s.dir, ".series")
+	_ = os.MkdirAll(legacy, 0755)
+	createConfig(path.Join(legacy, "index.xml"), "<seriesindex></seriesindex>")
+	_ = os.MkdirAll(path.Join(s.dir, "data"), 0755)
+	createConfig(path.Join(s.dir, "data/index.xml"), "<seriesindex></seriesindex>")
+
+	migration := LegacyMigration{
+		Directory:        legacy,
+		ConfigFile:       path.Join(s.dir, "config.json"),
+		Targets:          map[string]string{"index.xml": path.Join(s.dir, "data/index.xml")},
+		DefaultDirectory: s.dir,
+	}
+
+	_, err := migration.Run()
+	c.Assert(err, ErrorMatches, "can not move .*/.series/index.xml to .*/data/index.xml, it already exists")
+	c.Assert(util.IsFile(path.Join(legacy, "index.xml")), Equals, true)
+}
+
+func (s *MySuite) TestLegacyMigrationRollsBackOnError(c *C) {
+	legacy := path.Join(s.dir, ".series")
+	_ = os.MkdirAll(legacy, 0755)
+	createConfig(path.Join(legacy, "config.json"), "{}")
+	createConfig(path.Join(legacy, "index.xml"), "<seriesindex></seriesindex>")
+	createConfig(path.Join(legacy, "pending.json"), "[]")
+	createConfig(path.Join(s.dir, "state"), "not a directory")
+
+	migration := LegacyMigration{
+		Directory:  legacy,
+		ConfigFile: path.Join(s.dir, "config/config.json"),
+		Targets: map[string]string{
+			"config.json":  path.Join(s.dir, "config/config.json"),
+			"index.xml":    path.Join(s.dir, "data/index.xml"),
+			"pending.json": path.Join(s.dir, "state/pending.json"),
+		},
+		DefaultDirectory: path.Join(s.dir, "config"),
+	}
+
+	moves, err := migration.Run()
+	c.Assert(err, NotNil)
+	c.Assert(moves, HasLen, 0)
+	c.Assert(util.IsFile(path.Join(legacy, "config.json")), Equals, true)
+	c.Assert(util.IsFile(path.Join(legacy, "index.xml")), Equals, true)
+	c.Assert(util.PathExists(path.Join(s.dir, "data/index.xml")), Equals, false)
+	c.Assert(migration.Needed(), Equals, true)
+}
+
+func (s *MySuite) TestLegacyMigrationVersionControlled(c *C) {
+	legacy := path.Join(s.dir, ".series")
+	migration := LegacyMigration{Directory: legacy}
+
+	_ = os.MkdirAll(legacy, 0755)
+	c.Assert(migration.VersionControlled(), Equals, "")
+
+	_ = os.MkdirAll(path.Join(legacy, ".git"), 0755)
+	c.Assert(migration.VersionControlled(), Equals, path.Join(legacy, ".git"))
+}
+
+func (s *MySuite) TestValidateStreamsProviders(c *C) {
+	err := Validate(Config{StreamsProviders: []StreamsProvider{
+		{Name: "mirror", BaseURL: "https://mirror.example.org"},
+		{Name: "mirror", BaseURL: "https://other.example.org"},
+		{Name: DefaultStreamsProvider, BaseURL: "https://default.example.org"},
+		{Name: "incomplete"},
+	}})
+	c.Assert(err, NotNil)
+	c.Assert(err.(*ValidationError).Problems, DeepEquals, []string{
+		"StreamsProviders: name 'mirror' is used by more than one provider",
+		"StreamsProviders: provider 'https://default.example.org' needs a name other than 'default'",
+		"StreamsProviders: provider 'incomplete' has no BaseURL",
+	})
+}
+
+func (s *MySuite) TestResolveSecretsOfStreamsProviders(c *C) {
+	config := Config{StreamsProviders: []StreamsProvider{
+		{Name: "mirror", APITokenCommand: "echo mirror-token", AccountPassword: "plain"},
+	}}
+
+	c.Assert(config.ResolveSecrets(), IsNil)
+	c.Assert(config.StreamsProviders[0].APIToken, Equals, "mirror-token")
+	c.Assert(PlaintextSecrets(config), DeepEquals, []string{"StreamsProviders"})
+}
+
+func (s *MySuite) TestRedactSecrets(c *C) {
+	config := Config{
+		StreamsAPIToken:     "token",
+		StreamsAccountEmail: "me@example.org",
+		StreamsProviders:    []StreamsProvider{{Name: "mirror", AccountPassword: "password"}},
+	}
+
+	redacted := RedactSecrets(config)
+	c.Assert(redacted.StreamsAPIToken, Equals, Redacted)
+	c.Assert(redacted.StreamsAccountPassword, Equals, "")
+	c.Assert(redacted.StreamsAccountEmail, Equals, "me@example.org")
+	c.Assert(redacted.StreamsProviders[0].AccountPassword, Equals, Redacted)
+
+	// the original config is left alone
+	c.Assert(config.StreamsProviders[0].AccountPassword, Equals, "password")
+}
+
+func (s *MySuite) TestSecretEnviron(c *C) {
+	environ := []string{"PATH=/bin", "SERIES_STREAMS_API_TOKEN=token", "SERIES_INDEX_FILE=/index.xml",
+		"SERIES_STREAMS_ACCOUNT_PASSWORD=password", "SERIES_STREAMS_PROVIDERS=[]"}
+
+	c.Assert(WithoutSecretEnviron(environ), DeepEquals, []string{"PATH=/bin", "SERIES_INDEX_FILE=/index.xml"})
+	c.Assert(SecretEnviron(environ), DeepEquals, []string{"SERIES_STREAMS_API_TOKEN=token",
+		"SERIES_STREAMS_ACCOUNT_PASSWORD=password", "SERIES_STREAMS_PROVIDERS=[]"})
+}
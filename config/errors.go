@@ -0,0 +1,121 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// describeDecodeError turns errors of the JSON decoder into messages pointing
+// to the position in the config file
+func describeDecodeError(configFile string, content []byte, err error) error {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		// the offset points behind the invalid character
+		line, column := position(content, e.Offset-1)
+		return errors.New(fmt.Sprintf("%s:%d:%d: syntax error: %s", configFile, line, column, e))
+
+	case *json.UnmarshalTypeError:
+		line, column := position(content, e.Offset)
+		return errors.New(fmt.Sprintf("%s:%d:%d: %s has to be of type %s, not a JSON %s",
+			configFile, line, column, e.Field, e.Type, e.Value))
+	}
+
+	if strings.HasPrefix(err.Error(), "json: unknown field ") {
+		key := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+
+		message := fmt.Sprintf("%s: unknown key %q", configFile, key)
+		if suggestion := suggestField(key); suggestion != "" {
+			message += fmt.Sprintf(", did you mean %q?", suggestion)
+		}
+		return errors.New(message)
+	}
+
+	return errors.New(fmt.Sprintf("%s: %s", configFile, err))
+}
+
+// position returns the line and column of the offset, both starting at 1
+func position(content []byte, offset int64) (int, int) {
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	line, column := 1, 1
+	for _, b := range content[:offset] {
+		if b == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+
+	return line, column
+}
+
+// suggestField returns the name of the Config field which is most similar to
+// key or an empty string when there is no similar field
+func suggestField(key string) string {
+	best, bestDistance := "", len(key)/3+1
+
+	for _, name := range FieldNames() {
+		distance := levenshtein(strings.ToLower(key), strings.ToLower(name))
+		if distance < bestDistance {
+			best, bestDistance = name, distance
+		}
+	}
+
+	return best
+}
+
+// FieldNames returns the names of all Config fields
+func FieldNames() []string {
+	var names []string
+
+	configType := reflect.TypeOf(Config{})
+	for i := 0; i < configType.NumField(); i++ {
+		names = append(names, configType.Field(i).Name)
+	}
+
+	return names
+}
+
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current[j] = minimum(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous = current
+	}
+
+	return previous[len(b)]
+}
+
+func minimum(values ...int) int {
+	result := values[0]
+	for _, value := range values[1:] {
+		if value < result {
+			result = value
+		}
+	}
+	return result
+}
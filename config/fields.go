@@ -0,0 +1,60 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// field returns the settable field of the config whose name matches key case
+// insensitive
+func field(config *Config, key string) (reflect.Value, string, error) {
+	value := reflect.ValueOf(config).Elem()
+
+	for _, name := range FieldNames() {
+		if strings.EqualFold(name, key) {
+			return value.FieldByName(name), name, nil
+		}
+	}
+
+	message := fmt.Sprintf("unknown key %q", key)
+	if suggestion := suggestField(key); suggestion != "" {
+		message += fmt.Sprintf(", did you mean %q?", suggestion)
+	}
+	return reflect.Value{}, "", errors.New(message)
+}
+
+// GetField returns the value of the config field named key
+func GetField(config Config, key string) (interface{}, error) {
+	value, _, err := field(&config, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return value.Interface(), nil
+}
+
+// SetField sets the config field named key. Values of string fields are taken
+// literally, so that e.g. a password starting with a quote stays unchanged,
+// all other values are decoded as JSON.
+func SetField(config *Config, key string, value string) error {
+	fieldValue, name, err := field(config, key)
+	if err != nil {
+		return err
+	}
+
+	if fieldValue.Kind() == reflect.String {
+		fieldValue.SetString(value)
+		return nil
+	}
+
+	decoded := reflect.New(fieldValue.Type())
+	if err = json.Unmarshal([]byte(value), decoded.Interface()); err != nil {
+		return errors.New(fmt.Sprintf("%s has to be of type %s: %s", name, fieldValue.Type(), err))
+	}
+
+	fieldValue.Set(decoded.Elem())
+	return nil
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pboehm/series/util"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+)
+
+// LegacyMigration moves the entries of the former config directory ~/.series
+// to their new locations and adjusts the paths in the moved config file
+type LegacyMigration struct {
+	Directory  string
+	ConfigFile string
+
+	// Targets maps names of entries in Directory to their new paths, other
+	// entries are moved into DefaultDirectory
+	Targets          map[string]string
+	DefaultDirectory string
+}
+
+// Needed returns whether the legacy directory exists and the config file has
+// not been created at its new location yet
+func (m LegacyMigration) Needed() bool {
+	return util.IsDirectory(m.Directory) && !util.PathExists(m.ConfigFile)
+}
+
+// versionControlDirectories are created by version control systems
+var versionControlDirectories = []string{".git", ".hg", ".svn", ".bzr"}
+
+// VersionControlled returns the version control directory contained in the
+// legacy directory or "" when there is none. Such a directory can not be
+// split up without breaking the repository.
+func (m LegacyMigration) VersionControlled() string {
+	for _, name := range versionControlDirectories {
+		if util.PathExists(path.Join(m.Directory, name)) {
+			return path.Join(m.Directory, name)
+		}
+	}
+	return ""
+}
+
+// move is a single rename done by the migration
+type move struct {
+	source, target string
+}
+
+// Run moves the entries and returns their old and new paths. Nothing is moved
+// when one of the new paths already exists. The config file is moved last, so
+// that the legacy directory stays in use until everything else has been
+// moved. When a move fails, the entries moved before are moved back and only
+// the moves which could not be undone are returned together with the error.
+func (m LegacyMigration) Run() (map[string]string, error) {
+	entries, err := ioutil.ReadDir(m.Directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var moves []move
+	var configMove *move
+	for _, entry := range entries {
+		target, exists := m.Targets[entry.Name()]
+		if !exists {
+			target = path.Join(m.DefaultDirectory, entry.Name())
+		}
+
+		if util.PathExists(target) {
+			return nil, errors.New(fmt.Sprintf("can not move %s to %s, it already exists",
+				path.Join(m.Directory, entry.Name()), target))
+		}
+
+		if target == m.ConfigFile {
+			configMove = &move{path.Join(m.Directory, entry.Name()), target}
+		} else {
+			moves = append(moves, move{path.Join(m.Directory, entry.Name()), target})
+		}
+	}
+	if configMove != nil {
+		moves = append(moves, *configMove)
+	}
+
+	for i, mv := range moves {
+		if err = os.MkdirAll(path.Dir(mv.target), 0755); err == nil {
+			err = os.Rename(mv.source, mv.target)
+		}
+		if err != nil {
+			return rollback(moves[:i], err)
+		}
+	}
+
+	// the config file contains the default paths even when the files have
+	// not been created yet
+	replacements := map[string]string{}
+	for name, target := range m.Targets {
+		replacements[path.Join(m.Directory, name)] = target
+	}
+	for _, mv := range moves {
+		replacements[mv.source] = mv.target
+	}
+
+	if util.IsFile(m.ConfigFile) {
+		if err = replacePaths(m.ConfigFile, replacements); err != nil {
+			return rollback(moves, err)
+		}
+	}
+
+	done := map[string]string{}
+	for _, mv := range moves {
+		done[mv.source] = mv.target
+	}
+
+	return done, os.Remove(m.Directory)
+}
+
+// rollback moves the entries back in reverse order and returns the moves
+// which could not be undone
+func rollback(moves []move, cause error) (map[string]string, error) {
+	remaining := map[string]string{}
+	message := cause.Error()
+
+	for i := len(moves) - 1; i >= 0; i-- {
+		if err := os.Rename(moves[i].target, moves[i].source); err != nil {
+			remaining[moves[i].source] = moves[i].target
+			message += fmt.Sprintf(", moving %s back failed: %s", moves[i].target, err)
+		}
+	}
+
+	return remaining, errors.New(message)
+}
+
+// replacePaths replaces the old paths in the JSON file by the new ones, longer
+// paths are replaced first so that paths sharing a prefix are not mixed up
+func replacePaths(file string, replacements map[string]string) error {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var sources []string
+	for source := range replacements {
+		sources = append(sources, source)
+	}
+	sort.Slice(sources, func(i, j int) bool { return len(sources[i]) > len(sources[j]) })
+
+	for _, source := range sources {
+		content = bytes.Replace(content, jsonEncoded(source), jsonEncoded(replacements[source]), -1)
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, content, info.Mode().Perm())
+}
+
+// jsonEncoded returns the string as it is contained in JSON strings
+func jsonEncoded(str string) []byte {
+	encoded, _ := json.Marshal(str)
+	return encoded[1 : len(encoded)-1]
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Where the value of a config field comes from. Flags take precedence over
+// environment variables, which take precedence over the selected profile, the
+// config file and the defaults.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceProfile Source = "profile"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// Sources maps each config field to the source of its value
+type Sources map[string]Source
+
+// EnvPrefix is prepended to the upper snake case field name to get the
+// environment variable overriding the field, e.g. SERIES_INDEX_FILE
+const EnvPrefix = "SERIES_"
+
+// EnvName returns the environment variable overriding the field
+func EnvName(fieldName string) string {
+	var name []rune
+	runes := []rune(fieldName)
+
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			previousLower := unicode.IsLower(runes[i-1])
+			acronymEnds := unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if previousLower || acronymEnds {
+				name = append(name, '_')
+			}
+		}
+		name = append(name, unicode.ToUpper(r))
+	}
+
+	return EnvPrefix + string(name)
+}
+
+// LoadConfig reads the config file like GetConfig and applies the profile,
+// when one is given, the overrides from the environment and the `key=value`
+// flag overrides afterwards. The overrides are never written to the config
+// file.
+func LoadConfig(configFile string, standard Config, profile string, environ []string, flagOverrides []string) (Config, Sources, error) {
+	config, err := GetConfig(configFile, standard)
+	if err != nil {
+		return config, nil, err
+	}
+
+	sources := Sources{}
+	for _, name := range FieldNames() {
+		sources[name] = SourceDefault
+	}
+
+	fileKeys, err := configFileKeys(configFile)
+	if err != nil {
+		return config, nil, err
+	}
+	for _, key := range fileKeys {
+		// the config file is rewritten with all defaults, so only values
+		// differing from them count as set in the file
+		value, name, err := field(&config, key)
+		if err != nil {
+			continue
+		}
+
+		defaultValue, _, _ := field(&standard, key)
+		if !reflect.DeepEqual(value.Interface(), defaultValue.Interface()) {
+			sources[name] = SourceFile
+		}
+	}
+
+	if profile != "" {
+		var profileFields []string
+		if config, profileFields, err = ApplyProfile(config, profile); err != nil {
+			return config, nil, err
+		}
+		for _, name := range profileFields {
+			sources[name] = SourceProfile
+		}
+	}
+
+	environment := map[string]string{}
+	for _, variable := range environ {
+		parts := strings.SplitN(variable, "=", 2)
+		if len(parts) == 2 {
+			environment[parts[0]] = parts[1]
+		}
+	}
+
+	for _, name := range FieldNames() {
+		value, exists := environment[EnvName(name)]
+		if !exists {
+			continue
+		}
+
+		if err := SetField(&config, name, value); err != nil {
+			return config, nil, errors.New(fmt.Sprintf("%s: %s", EnvName(name), err))
+		}
+		sources[name] = SourceEnv
+	}
+
+	for _, override := range flagOverrides {
+		parts := strings.SplitN(override, "=", 2)
+		if len(parts) != 2 {
+			return config, nil, errors.New(fmt.Sprintf("override '%s' has not the format key=value", override))
+		}
+
+		_, name, err := field(&config, parts[0])
+		if err == nil {
+			err = SetField(&config, name, parts[1])
+		}
+		if err != nil {
+			return config, nil, errors.New(fmt.Sprintf("--set %s: %s", override, err))
+		}
+		sources[name] = SourceFlag
+	}
+
+	return config, sources, nil
+}
+
+func configFileKeys(configFile string) ([]string, error) {
+	content, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]json.RawMessage
+	if err = json.Unmarshal(content, &values); err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
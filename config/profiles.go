@@ -0,0 +1,88 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ProfileNames returns the sorted names of the configured profiles
+func ProfileNames(config Config) []string {
+	var names []string
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyProfile returns the config with the values of the named profile
+// decoded over it and the names of the fields set by the profile. Lists are
+// replaced by the profile, the events of `Hooks` are merged.
+func ApplyProfile(config Config, name string) (Config, []string, error) {
+	raw, exists := config.Profiles[name]
+	if !exists {
+		return config, nil, errors.New(fmt.Sprintf("profile '%s' does not exist, available profiles: %s",
+			name, strings.Join(ProfileNames(config), ", ")))
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return config, nil, errors.New(fmt.Sprintf("profile '%s': %s", name, err))
+	}
+
+	var fields []string
+	for key := range values {
+		_, fieldName, err := field(&config, key)
+		if err != nil {
+			return config, nil, errors.New(fmt.Sprintf("profile '%s': %s", name, err))
+		}
+		if fieldName == "Profiles" {
+			return config, nil, errors.New(fmt.Sprintf("profile '%s': profiles can not be nested", name))
+		}
+		fields = append(fields, fieldName)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&config); err != nil {
+		return config, nil, describeDecodeError(fmt.Sprintf("profile '%s'", name), raw, err)
+	}
+
+	sort.Strings(fields)
+	return config, fields, nil
+}
+
+// SetProfileField sets the config field named key in the named profile
+func SetProfileField(config *Config, profile, key, value string) error {
+	effective, _, err := ApplyProfile(*config, profile)
+	if err != nil {
+		return err
+	}
+
+	if err = SetField(&effective, key, value); err != nil {
+		return err
+	}
+	fieldValue, name, _ := field(&effective, key)
+
+	values := map[string]json.RawMessage{}
+	if err = json.Unmarshal(config.Profiles[profile], &values); err != nil {
+		return err
+	}
+	for existing := range values {
+		if strings.EqualFold(existing, name) {
+			delete(values, existing)
+		}
+	}
+
+	if values[name], err = json.Marshal(fieldValue.Interface()); err != nil {
+		return err
+	}
+
+	config.Profiles[profile], err = json.Marshal(values)
+	return err
+}
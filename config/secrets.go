@@ -0,0 +1,208 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// secret is a config value which can also be read from a file or be printed
+// by a command, so that it does not have to be stored in the config file
+type secret struct {
+	name          string
+	value         *string
+	file, command string
+
+	// field is the Config field containing the secret
+	field string
+}
+
+func (c *Config) secrets() []secret {
+	secrets := []secret{
+		{"StreamsAPIToken", &c.StreamsAPIToken, c.StreamsAPITokenFile, c.StreamsAPITokenCommand,
+			"StreamsAPIToken"},
+		{"StreamsAccountPassword", &c.StreamsAccountPassword, c.StreamsAccountPasswordFile,
+			c.StreamsAccountPasswordCommand, "StreamsAccountPassword"},
+	}
+
+	for i := range c.StreamsProviders {
+		provider := &c.StreamsProviders[i]
+		prefix := fmt.Sprintf("StreamsProviders[%s].", provider.Name)
+
+		secrets = append(secrets,
+			secret{prefix + "APIToken", &provider.APIToken, provider.APITokenFile, provider.APITokenCommand,
+				"StreamsProviders"},
+			secret{prefix + "AccountPassword", &provider.AccountPassword, provider.AccountPasswordFile,
+				provider.AccountPasswordCommand, "StreamsProviders"},
+		)
+	}
+
+	return secrets
+}
+
+// ResolveSecrets sets the secrets which are configured by a file or a command.
+// Secrets given directly, e.g. by an environment variable, are left alone.
+func (c *Config) ResolveSecrets() error {
+	for _, s := range c.secrets() {
+		if *s.value != "" {
+			continue
+		}
+
+		var err error
+		switch {
+		case s.file != "":
+			*s.value, err = ReadSecretFile(s.file)
+		case s.command != "":
+			*s.value, err = RunSecretCommand(s.command)
+		}
+
+		if err != nil {
+			return errors.New(fmt.Sprintf("%s: %s", s.name, err))
+		}
+	}
+
+	return nil
+}
+
+// PlaintextSecrets returns the names of the fields containing secrets
+func PlaintextSecrets(config Config) []string {
+	var names []string
+
+	for _, s := range config.secrets() {
+		if *s.value != "" && !contains(names, s.field) {
+			names = append(names, s.field)
+		}
+	}
+
+	for _, webhook := range config.Webhooks {
+		if webhook.Secret != "" {
+			names = append(names, "Webhooks")
+			break
+		}
+	}
+
+	return names
+}
+
+// CheckSecretFile checks that the file is only accessible by its owner
+func CheckSecretFile(file string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	if info.Mode().Perm()&0077 != 0 {
+		return errors.New(fmt.Sprintf("%s is accessible by other users (mode %04o), run: chmod 600 %s",
+			file, info.Mode().Perm(), file))
+	}
+
+	return nil
+}
+
+// ReadSecretFile returns the content of the file without surrounding
+// whitespace
+func ReadSecretFile(file string) (string, error) {
+	if err := CheckSecretFile(file); err != nil {
+		return "", err
+	}
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
+// RunSecretCommand returns the first line printed by the shell command, like
+// the password printed by `pass show`
+func RunSecretCommand(command string) (string, error) {
+	var stdout bytes.Buffer
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", errors.New(fmt.Sprintf("command '%s' failed: %s", command, err))
+	}
+
+	line := strings.TrimSpace(strings.SplitN(stdout.String(), "\n", 2)[0])
+	if line == "" {
+		return "", errors.New(fmt.Sprintf("command '%s' printed nothing", command))
+	}
+
+	return line, nil
+}
+
+// WorldReadable returns whether every user can read the file
+func WorldReadable(file string) bool {
+	info, err := os.Stat(file)
+	return err == nil && info.Mode().Perm()&0004 != 0
+}
+
+// Redacted replaces secrets when the config is shown
+const Redacted = "<redacted>"
+
+// RedactSecrets returns a copy of the config whose secrets are replaced by
+// Redacted
+func RedactSecrets(config Config) Config {
+	config.StreamsProviders = append([]StreamsProvider(nil), config.StreamsProviders...)
+
+	for _, s := range config.secrets() {
+		if *s.value != "" {
+			*s.value = Redacted
+		}
+	}
+
+	return config
+}
+
+// secretEnvNames returns the environment variables overriding fields which
+// contain secrets
+func secretEnvNames() []string {
+	var names []string
+
+	all := Config{StreamsProviders: []StreamsProvider{{}}}
+	for _, s := range all.secrets() {
+		if name := EnvName(s.field); !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// WithoutSecretEnviron returns the environment without the variables
+// overriding fields which contain secrets, so that commands started by series
+// do not inherit them
+func WithoutSecretEnviron(environ []string) []string {
+	var filtered []string
+	for _, variable := range environ {
+		if !isSecretVariable(variable) {
+			filtered = append(filtered, variable)
+		}
+	}
+	return filtered
+}
+
+// SecretEnviron returns the variables of the environment overriding fields
+// which contain secrets
+func SecretEnviron(environ []string) []string {
+	var secrets []string
+	for _, variable := range environ {
+		if isSecretVariable(variable) {
+			secrets = append(secrets, variable)
+		}
+	}
+	return secrets
+}
+
+func isSecretVariable(variable string) bool {
+	return contains(secretEnvNames(), strings.SplitN(variable, "=", 2)[0])
+}
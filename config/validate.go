@@ -0,0 +1,210 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pboehm/series/hooks"
+	"github.com/pboehm/series/util"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// What happens to episodes of series which are not part of the index
+const (
+	UnknownSeriesIgnore  = "ignore"
+	UnknownSeriesQueue   = "queue"
+	UnknownSeriesPrompt  = "prompt"
+	UnknownSeriesAutoAdd = "auto-add"
+)
+
+var UnknownSeriesPolicies = []string{UnknownSeriesIgnore, UnknownSeriesQueue, UnknownSeriesPrompt, UnknownSeriesAutoAdd}
+
+// DefaultStreamsProvider is the name of the provider configured by the
+// Streams* settings
+const DefaultStreamsProvider = "default"
+
+var Extractors = []string{"filesystem", "regex", "mapping", "script"}
+
+// ValidationError lists all problems found by Validate
+type ValidationError struct {
+	Problems []string
+}
+
+func (v *ValidationError) Error() string {
+	return "invalid config:\n  " + strings.Join(v.Problems, "\n  ")
+}
+
+// Validate checks that the configured hooks and scripts exist, the configured
+// directories exist and the remaining values are valid
+func Validate(config Config) error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	for name, command := range map[string]string{
+		"PreProcessingHook":  config.PreProcessingHook,
+		"PostProcessingHook": config.PostProcessingHook,
+		"EpisodeHook":        config.EpisodeHook,
+		"UnknownSeriesHook":  config.UnknownSeriesHook,
+	} {
+		if err := checkCommand(command); err != nil {
+			problem("%s: %s", name, err)
+		}
+	}
+
+	for event, handlers := range config.Hooks {
+		for _, handler := range handlers {
+			if err := checkCommand(handler.Command); err != nil {
+				problem("Hooks %s: %s", event, err)
+			}
+		}
+	}
+	if err := hooks.Validate(config.Hooks); err != nil {
+		problem("Hooks: %s", err)
+	}
+	if err := hooks.ValidateWebhooks(config.Webhooks); err != nil {
+		problem("Webhooks: %s", err)
+	}
+
+	for _, script := range config.ScriptExtractors {
+		if err := checkExecutable(script.Path); err != nil {
+			problem("ScriptExtractors: %s", err)
+		}
+	}
+
+	for name, directory := range map[string]string{
+		"EpisodeDirectory": config.EpisodeDirectory,
+		"LibraryDirectory": config.LibraryDirectory,
+	} {
+		if directory != "" && !util.IsDirectory(directory) {
+			problem("%s: '%s' is not a directory", name, directory)
+		}
+	}
+	if config.IndexFile != "" && !util.IsDirectory(path.Dir(config.IndexFile)) {
+		problem("IndexFile: directory '%s' does not exist", path.Dir(config.IndexFile))
+	}
+	if config.SeriesNameMappingFile != "" && !util.IsFile(config.SeriesNameMappingFile) {
+		problem("SeriesNameMappingFile: '%s' does not exist", config.SeriesNameMappingFile)
+	}
+
+	for _, rule := range config.SeriesNameRules {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			problem("SeriesNameRules: invalid pattern '%s': %s", rule.Pattern, err)
+		}
+	}
+
+	for _, extractor := range config.ExtractorOrder {
+		if !contains(Extractors, extractor) {
+			problem("ExtractorOrder: unknown extractor '%s', use one of: %s",
+				extractor, strings.Join(Extractors, ", "))
+		}
+	}
+
+	if config.UnknownSeriesPolicy != "" && !contains(UnknownSeriesPolicies, config.UnknownSeriesPolicy) {
+		problem("UnknownSeriesPolicy: unknown policy '%s', use one of: %s",
+			config.UnknownSeriesPolicy, strings.Join(UnknownSeriesPolicies, ", "))
+	}
+
+	for _, s := range config.secrets() {
+		if s.file != "" && s.command != "" {
+			problem("%s: only one of %sFile and %sCommand can be set", s.name, s.name, s.name)
+		}
+		if s.file != "" {
+			if err := CheckSecretFile(s.file); err != nil {
+				problem("%sFile: %s", s.name, err)
+			}
+		}
+		if s.command != "" {
+			if err := checkCommand(s.command); err != nil {
+				problem("%sCommand: %s", s.name, err)
+			}
+		}
+	}
+
+	for name, actions := range map[string][]StreamAction{
+		"StreamsGlobalActions": config.StreamsGlobalActions,
+		"StreamsLinkActions":   config.StreamsLinkActions,
+	} {
+		ids := map[string]bool{}
+		for _, action := range actions {
+			if action.Id == "" {
+				problem("%s: action '%s' has no Id", name, action.Title)
+			} else if ids[action.Id] {
+				problem("%s: Id '%s' is used by more than one action", name, action.Id)
+			}
+			ids[action.Id] = true
+		}
+	}
+
+	providers := map[string]bool{}
+	for _, provider := range config.StreamsProviders {
+		if provider.Name == "" || provider.Name == DefaultStreamsProvider {
+			problem("StreamsProviders: provider '%s' needs a name other than '%s'", provider.BaseURL,
+				DefaultStreamsProvider)
+		} else if providers[provider.Name] {
+			problem("StreamsProviders: name '%s' is used by more than one provider", provider.Name)
+		}
+		providers[provider.Name] = true
+
+		if provider.BaseURL == "" {
+			problem("StreamsProviders: provider '%s' has no BaseURL", provider.Name)
+		}
+	}
+
+	for _, name := range ProfileNames(config) {
+		if _, _, err := ApplyProfile(config, name); err != nil {
+			problem("Profiles: %s", err)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+var shellBuiltins = []string{".", ":", "[", "cd", "echo", "exec", "exit", "export", "for", "if", "printf",
+	"set", "test", "true", "false", "while", "{", "("}
+
+// checkCommand checks that the executable of a shell command exists, leading
+// variable assignments and shell builtins are skipped
+func checkCommand(command string) error {
+	for _, field := range strings.Fields(command) {
+		if strings.Contains(field, "=") && !strings.Contains(field, "/") {
+			continue
+		}
+		if contains(shellBuiltins, field) {
+			return nil
+		}
+
+		return checkExecutable(field)
+	}
+
+	return nil
+}
+
+func checkExecutable(executable string) error {
+	if strings.Contains(executable, "/") {
+		if !util.IsFile(executable) {
+			return errors.New(fmt.Sprintf("'%s' does not exist", executable))
+		}
+	}
+
+	if _, err := exec.LookPath(executable); err != nil {
+		return errors.New(fmt.Sprintf("'%s' is not executable", executable))
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
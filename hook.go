@@ -1,58 +1,133 @@
 package main
 
 import (
-	"fmt"
+	"github.com/pboehm/series/hooks"
+	"github.com/pboehm/series/renamer"
 	"io"
-	"os"
 	"os/exec"
+	"time"
 )
 
-// This executes the supplied cmd by /bin/sh and returns an error if it returns
-// unexpectedly
-func System(cmdString string) error {
-	return SystemV(cmdString, []string{}, os.Stderr, os.Stderr)
-}
-
+// SystemV executes the supplied cmd by /bin/sh with the additional environment
+// variables and returns an error if it returns unexpectedly
 func SystemV(cmdString string, extraEnviron []string, stdout io.Writer, stderr io.Writer) error {
 	cmd := exec.Command("/bin/sh", "-c", cmdString)
-	cmd.Env = append(os.Environ(), extraEnviron...)
+	cmd.Env = append(hooks.InheritedEnviron(), extraEnviron...)
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
 	return cmd.Run()
 }
 
-func callPreProcessingHook() {
-	if appConfig.PreProcessingHook != "" {
-		LOG.Println("### Calling PreProcessingHook ...")
+// hookSummary records all hook executions, it is printed at the end of each
+// command and exposed by the streams server
+var hookSummary = &hooks.Summary{Limit: 100}
 
-		err := System(appConfig.PreProcessingHook)
-		if err != nil {
-			LOG.Printf("PreProcessingHook ended with an error: %s\n", err)
-		}
+// runHook executes one of the single command hooks with the configured
+// timeout and retries. Its output is prefixed with the name of the hook.
+func runHook(name, command string, args ...string) {
+	if command == "" {
+		return
+	}
+
+	LOG.Printf("# Calling %s ...\n", name)
+
+	execution, err := hooks.Execute(hooks.Command{
+		Name:    name,
+		Command: command,
+		Args:    args,
+		Environ: configEnviron(),
+		Timeout: time.Duration(appConfig.HookTimeout) * time.Second,
+		Retries: appConfig.HookRetries,
+	}, LOG.Writer())
+	hookSummary.Add(execution)
+
+	if err != nil {
+		LOG.Printf("%s ended with an error: %s\n", name, err)
 	}
 }
 
+func callPreProcessingHook() {
+	runHook("PreProcessingHook", appConfig.PreProcessingHook)
+}
+
 func callPostProcessingHook() {
-	if appConfig.PostProcessingHook != "" {
-		LOG.Println("\n### Calling PostProcessingHook ...")
+	runHook("PostProcessingHook", appConfig.PostProcessingHook)
+}
 
-		err := System(appConfig.PostProcessingHook)
-		if err != nil {
-			LOG.Printf("PostProcessingHook ended with an error: %s\n", err)
-		}
-	}
+func callUnknownSeriesHook(episodePath, seriesName string) {
+	runHook("UnknownSeriesHook", appConfig.UnknownSeriesHook, episodePath, seriesName)
 }
 
 func callEpisodeHook(episodePath, seriesName string) {
-	if appConfig.EpisodeHook != "" {
-		LOG.Println("# Calling EpisodeHook ...")
+	runHook("EpisodeHook", appConfig.EpisodeHook, episodePath, seriesName)
+}
 
-		hookCmd := fmt.Sprintf("%s \"%s\" \"%s\"",
-			appConfig.EpisodeHook, episodePath, seriesName)
+// webhookNotifier posts the events to the `Webhooks` in the background, so
+// that an unreachable webhook does not block the commands
+var webhookNotifier *hooks.Notifier
 
-		err := System(hookCmd)
-		if err != nil {
-			LOG.Printf("EpisodeHook ended with an error: %s\n", err)
+// emitEvent runs the handlers configured for the event in `Hooks` and
+// notifies the `Webhooks`. The error of a failing handler with OnFailure
+// "abort" is returned, so that CLI commands can stop while the streams server
+// only logs it. Failing webhooks are only logged.
+func emitEvent(event string, payload hooks.Payload) error {
+	if len(appConfig.Webhooks) > 0 {
+		if webhookNotifier == nil {
+			webhookNotifier = hooks.NewNotifier(appConfig.Webhooks)
 		}
+		webhookNotifier.NotifyInBackground(event, payload, func(err error) {
+			LOG.Printf("!!! Notifying about %s failed: %s\n", event, err)
+		})
+	}
+
+	if len(appConfig.Hooks[event]) == 0 {
+		return nil
+	}
+
+	LOG.Printf("# Calling %s hooks ...\n", event)
+
+	runner := hooks.Runner{
+		Handlers:       appConfig.Hooks,
+		Environ:        configEnviron(),
+		DefaultTimeout: time.Duration(appConfig.HookTimeout) * time.Second,
+		Output:         LOG.Writer(),
+		Summary:        hookSummary,
+		Warn: func(event string, handler hooks.Handler, err error) {
+			LOG.Printf("%s hook '%s' ended with an error: %s\n", event, handler.Command, err)
+		},
+	}
+
+	return runner.Run(event, payload)
+}
+
+// waitForWebhooks gives the webhooks notified in the background the time to
+// finish before the process exits
+func waitForWebhooks() {
+	if webhookNotifier == nil {
+		return
+	}
+
+	if !webhookNotifier.Wait(webhookNotifier.MaxDuration) {
+		LOG.Printf("!!! Not all webhooks could be notified within %s\n", webhookNotifier.MaxDuration)
+	}
+}
+
+func printHookSummary() {
+	if len(hookSummary.Executions()) == 0 {
+		return
+	}
+
+	LOG.Println("\n### Hook summary")
+	hookSummary.Print(LOG.Writer())
+}
+
+func episodePayload(episode *renamer.Episode) hooks.Payload {
+	return hooks.Payload{
+		"series":   episode.Series,
+		"season":   episode.Season,
+		"episode":  episode.Episode,
+		"name":     episode.Name,
+		"language": episode.Language,
+		"filename": episode.CleanedFileName(),
 	}
 }
@@ -0,0 +1,211 @@
+package hooks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is used for commands without a timeout
+const DefaultTimeout = 5 * time.Minute
+
+// maxCapturedOutput limits the output stored in an Execution, the end of the
+// output is kept
+const maxCapturedOutput = 4096
+
+// Command is a hook command run by `/bin/sh -c`
+type Command struct {
+	// Name is used as prefix for the output and in the summary
+	Name    string
+	Command string
+	Args    []string
+	Environ []string
+	Stdin   []byte
+	Timeout time.Duration
+	Retries int
+}
+
+// Execution describes the execution of a Command including all retries
+type Execution struct {
+	Name       string        `json:"name"`
+	Command    string        `json:"command"`
+	Started    time.Time     `json:"started"`
+	Duration   time.Duration `json:"duration"`
+	Attempts   int           `json:"attempts"`
+	ExitStatus int           `json:"exit_status"`
+	TimedOut   bool          `json:"timed_out"`
+	Error      string        `json:"error,omitempty"`
+	Output     string        `json:"output,omitempty"`
+}
+
+func (e Execution) Success() bool {
+	return e.Error == ""
+}
+
+// Execute runs the command until it succeeds or all retries are used up. Each
+// line of its output is written with a `[<name>] ` prefix to output.
+func Execute(command Command, output io.Writer) (Execution, error) {
+	timeout := command.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	execution := Execution{Name: command.Name, Command: command.Command, Started: time.Now()}
+	captured := &tailBuffer{limit: maxCapturedOutput}
+	prefixed := &prefixWriter{prefix: fmt.Sprintf("[%s] ", command.Name), out: output}
+	defer prefixed.Flush()
+
+	var err error
+	for execution.Attempts < command.Retries+1 {
+		execution.Attempts++
+		err = executeOnce(command, timeout, io.MultiWriter(prefixed, captured), &execution)
+		if err == nil {
+			break
+		}
+	}
+
+	execution.Duration = time.Since(execution.Started)
+	execution.Output = captured.String()
+	if err != nil {
+		execution.Error = err.Error()
+	}
+
+	return execution, err
+}
+
+// InheritedEnviron returns the environment which commands inherit, the
+// variables of Command.Environ are added to it
+var InheritedEnviron = os.Environ
+
+func executeOnce(command Command, timeout time.Duration, output io.Writer, execution *Execution) error {
+	cmd := exec.Command("/bin/sh", ShellArgs(command.Command, command.Args...)...)
+	cmd.Env = append(InheritedEnviron(), command.Environ...)
+	cmd.Stdin = bytes.NewReader(command.Stdin)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	setProcessGroup(cmd)
+
+	execution.TimedOut = false
+	execution.ExitStatus = 0
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(timeout):
+		killProcessGroup(cmd)
+		<-done
+		execution.TimedOut = true
+		err = errors.New(fmt.Sprintf("timed out after %s", timeout))
+	}
+
+	if cmd.ProcessState != nil {
+		execution.ExitStatus = cmd.ProcessState.ExitCode()
+	}
+
+	return err
+}
+
+// Summary collects the executions of all hooks run by a process. When Limit
+// is set, only the latest Limit executions are kept.
+type Summary struct {
+	Limit int
+
+	mutex      sync.Mutex
+	executions []Execution
+}
+
+func (s *Summary) Add(execution Execution) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.executions = append(s.executions, execution)
+	if s.Limit > 0 && len(s.executions) > s.Limit {
+		s.executions = s.executions[len(s.executions)-s.Limit:]
+	}
+}
+
+func (s *Summary) Executions() []Execution {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]Execution{}, s.executions...)
+}
+
+// Print writes one line per execution
+func (s *Summary) Print(out io.Writer) {
+	for _, execution := range s.Executions() {
+		status := "ok"
+		if execution.TimedOut {
+			status = "timed out"
+		} else if !execution.Success() {
+			status = fmt.Sprintf("failed with exit status %d", execution.ExitStatus)
+		}
+
+		fmt.Fprintf(out, "  %-30s %-28s %8s  %d attempt(s)\n",
+			execution.Name, status, execution.Duration.Round(time.Millisecond), execution.Attempts)
+	}
+}
+
+// prefixWriter writes each complete line with the prefix
+type prefixWriter struct {
+	prefix string
+	out    io.Writer
+	line   []byte
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	if p.out == nil {
+		return len(data), nil
+	}
+
+	for _, b := range data {
+		p.line = append(p.line, b)
+		if b == '\n' {
+			if _, err := p.out.Write(append([]byte(p.prefix), p.line...)); err != nil {
+				return 0, err
+			}
+			p.line = p.line[:0]
+		}
+	}
+
+	return len(data), nil
+}
+
+// Flush writes an incomplete last line
+func (p *prefixWriter) Flush() {
+	if len(p.line) > 0 && p.out != nil {
+		p.out.Write(append(append([]byte(p.prefix), p.line...), '\n'))
+		p.line = p.line[:0]
+	}
+}
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	limit int
+	data  []byte
+}
+
+func (t *tailBuffer) Write(data []byte) (int, error) {
+	t.data = append(t.data, data...)
+	if len(t.data) > t.limit {
+		t.data = t.data[len(t.data)-t.limit:]
+	}
+	return len(data), nil
+}
+
+func (t *tailBuffer) String() string {
+	return string(t.data)
+}
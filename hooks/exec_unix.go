@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package hooks
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup gives the command its own process group, so that processes
+// started by it are killed on timeout too and do not keep the output open
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
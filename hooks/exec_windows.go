@@ -0,0 +1,14 @@
+package hooks
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup does nothing, there are no process groups on windows
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills only the command itself, processes started by it
+// keep running
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
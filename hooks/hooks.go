@@ -0,0 +1,203 @@
+package hooks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Events which handlers can be configured for
+const (
+	EpisodeRenamed     = "episode.renamed"
+	EpisodeIndexed     = "episode.indexed"
+	IndexWritten       = "index.written"
+	SeriesAdded        = "series.added"
+	SeriesUnknown      = "series.unknown"
+	StreamsNewEpisodes = "streams.new_episodes"
+)
+
+var Events = []string{EpisodeRenamed, EpisodeIndexed, IndexWritten, SeriesAdded, SeriesUnknown, StreamsNewEpisodes}
+
+// What happens when a handler fails
+const (
+	FailureWarn  = "warn"
+	FailureAbort = "abort"
+)
+
+// Handler is a command which is run by `/bin/sh -c` for an event. It gets the
+// payload as JSON on stdin and the scalar payload values as
+// `SERIES_EVENT_<KEY>` environment variables. Failed executions are retried Retries times.
+type Handler struct {
+	Command string
+
+	// OnFailure is either "warn" (default) or "abort"
+	OnFailure string `json:",omitempty"`
+
+	// Timeout in seconds, the Runner's default is used when not set
+	Timeout int `json:",omitempty"`
+	Retries int `json:",omitempty"`
+}
+
+func (h Handler) aborts() bool {
+	return h.OnFailure == FailureAbort
+}
+
+// Payload is the data passed to the handlers of an event
+type Payload map[string]interface{}
+
+// AbortError is returned by Run when a handler with OnFailure "abort" fails
+type AbortError struct {
+	Event   string
+	Command string
+	Err     error
+}
+
+func (e *AbortError) Error() string {
+	return fmt.Sprintf("%s hook '%s' failed: %s", e.Event, e.Command, e.Err)
+}
+
+type Runner struct {
+	Handlers map[string][]Handler
+
+	// Environ is added to the environment of each handler
+	Environ []string
+
+	// DefaultTimeout is used for handlers without a timeout
+	DefaultTimeout time.Duration
+
+	// Output gets the prefixed output of the handlers
+	Output io.Writer
+
+	// Summary records each execution if set
+	Summary *Summary
+
+	// Warn is called for handlers failing with OnFailure "warn"
+	Warn func(event string, handler Handler, err error)
+}
+
+// Validate checks that handlers are only configured for known events and have
+// a valid failure mode
+func Validate(handlers map[string][]Handler) error {
+	for event, eventHandlers := range handlers {
+		if !isEvent(event) {
+			return errors.New(fmt.Sprintf("unknown hook event '%s', use one of: %s",
+				event, strings.Join(Events, ", ")))
+		}
+
+		for _, handler := range eventHandlers {
+			if handler.OnFailure != "" && handler.OnFailure != FailureWarn && handler.OnFailure != FailureAbort {
+				return errors.New(fmt.Sprintf("invalid OnFailure '%s' for %s hook '%s', use %s or %s",
+					handler.OnFailure, event, handler.Command, FailureWarn, FailureAbort))
+			}
+		}
+	}
+
+	return nil
+}
+
+func isEvent(event string) bool {
+	for _, known := range Events {
+		if known == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Run executes the handlers of the event one after another. It stops and
+// returns an AbortError as soon as a handler with OnFailure "abort" fails.
+func (r *Runner) Run(event string, payload Payload) error {
+	handlers := r.Handlers[event]
+	if len(handlers) == 0 {
+		return nil
+	}
+
+	input, err := json.Marshal(payloadWithEvent(event, payload))
+	if err != nil {
+		return err
+	}
+
+	environ := append(append([]string{}, r.Environ...), Environment(event, payload)...)
+
+	for i, handler := range handlers {
+		timeout := time.Duration(handler.Timeout) * time.Second
+		if timeout <= 0 {
+			timeout = r.DefaultTimeout
+		}
+
+		execution, err := Execute(Command{
+			Name:    fmt.Sprintf("%s #%d", event, i+1),
+			Command: handler.Command,
+			Environ: environ,
+			Stdin:   input,
+			Timeout: timeout,
+			Retries: handler.Retries,
+		}, r.Output)
+
+		if r.Summary != nil {
+			r.Summary.Add(execution)
+		}
+
+		if err != nil {
+			if handler.aborts() {
+				return &AbortError{Event: event, Command: handler.Command, Err: err}
+			}
+
+			if r.Warn != nil {
+				r.Warn(event, handler, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func payloadWithEvent(event string, payload Payload) Payload {
+	withEvent := Payload{"event": event}
+	for key, value := range payload {
+		withEvent[key] = value
+	}
+	return withEvent
+}
+
+// EventEnvPrefix is prepended to the upper case payload keys. It differs from
+// the prefix of the config overrides, so that a hook running series again
+// does not change its config by a payload value like `index_file`.
+const EventEnvPrefix = "SERIES_EVENT_"
+
+// Environment returns `SERIES_EVENT` and a `SERIES_EVENT_<KEY>` variable for
+// each scalar value of the payload, sorted by name
+func Environment(event string, payload Payload) []string {
+	environ := []string{fmt.Sprintf("SERIES_EVENT=%s", event)}
+
+	var keys []string
+	for key := range payload {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := EventEnvPrefix + strings.ToUpper(key)
+
+		switch value := payload[key].(type) {
+		case string, bool, int, int64, float64:
+			environ = append(environ, fmt.Sprintf("%s=%v", name, value))
+		}
+	}
+
+	return environ
+}
+
+// ShellArgs builds the arguments for running command by `/bin/sh -c` with args
+// appended as positional parameters, so that they are never interpreted by
+// the shell
+func ShellArgs(command string, args ...string) []string {
+	if len(args) == 0 {
+		return []string{"-c", command}
+	}
+	return append([]string{"-c", command + ` "$@"`, "sh"}, args...)
+}
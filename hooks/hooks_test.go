@@ -0,0 +1,138 @@
+package hooks
+
+import (
+	"bytes"
+	"encoding/json"
+	. "launchpad.net/gocheck"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+var _ = Suite(&MySuite{})
+
+type MySuite struct{}
+
+func (s *MySuite) TestPayloadAndEnvironment(c *C) {
+	var output bytes.Buffer
+	runner := Runner{
+		Handlers: map[string][]Handler{
+			EpisodeRenamed: {{Command: `cat; echo; echo "$SERIES_EVENT|$SERIES_EVENT_SERIES|$SERIES_EVENT_SEASON"`}},
+		},
+		Output: &output,
+	}
+
+	err := runner.Run(EpisodeRenamed, Payload{"series": "Shameless US", "season": 1, "files": []string{"a"}})
+	c.Assert(err, IsNil)
+
+	lines := strings.Split(output.String(), "\n")
+	c.Assert(lines, HasLen, 3)
+	c.Assert(strings.HasPrefix(lines[0], "[episode.renamed #1] {"), Equals, true)
+
+	var payload map[string]interface{}
+	c.Assert(json.Unmarshal([]byte(strings.TrimPrefix(lines[0], "[episode.renamed #1] ")), &payload), IsNil)
+	c.Assert(payload["event"], Equals, EpisodeRenamed)
+	c.Assert(payload["series"], Equals, "Shameless US")
+	c.Assert(lines[1], Equals, "[episode.renamed #1] episode.renamed|Shameless US|1")
+}
+
+func (s *MySuite) TestEnvironmentContainsOnlyScalars(c *C) {
+	environ := Environment(SeriesAdded, Payload{"series": "Dr. House", "language": "de", "episodes": []int{1}})
+	c.Assert(environ, DeepEquals, []string{
+		"SERIES_EVENT=series.added", "SERIES_EVENT_LANGUAGE=de", "SERIES_EVENT_SERIES=Dr. House"})
+
+	// payload values never look like config overrides
+	environ = Environment(IndexWritten, Payload{"index_file": "/index.xml"})
+	c.Assert(environ, DeepEquals, []string{"SERIES_EVENT=index.written", "SERIES_EVENT_INDEX_FILE=/index.xml"})
+}
+
+func (s *MySuite) TestFailureModes(c *C) {
+	var warned []string
+	runner := Runner{
+		Handlers: map[string][]Handler{
+			IndexWritten: {
+				{Command: "exit 1"},
+				{Command: "exit 2", OnFailure: FailureAbort},
+				{Command: "echo not reached"},
+			},
+		},
+		Warn: func(event string, handler Handler, err error) {
+			warned = append(warned, handler.Command)
+		},
+	}
+
+	err := runner.Run(IndexWritten, Payload{})
+	c.Assert(err, ErrorMatches, "index.written hook 'exit 2' failed: exit status 2")
+	c.Assert(warned, DeepEquals, []string{"exit 1"})
+
+	c.Assert(runner.Run(EpisodeIndexed, Payload{}), IsNil)
+}
+
+func (s *MySuite) TestValidate(c *C) {
+	c.Assert(Validate(map[string][]Handler{EpisodeRenamed: {{Command: "true"}}}), IsNil)
+	c.Assert(Validate(map[string][]Handler{"episode.deleted": {{Command: "true"}}}),
+		ErrorMatches, "unknown hook event 'episode.deleted'.*")
+	c.Assert(Validate(map[string][]Handler{EpisodeRenamed: {{Command: "true", OnFailure: "ignore"}}}),
+		ErrorMatches, "invalid OnFailure 'ignore'.*")
+}
+
+func (s *MySuite) TestShellArgsAreNotInterpreted(c *C) {
+	output, err := exec.Command("/bin/sh", ShellArgs("printf '%s|'", `a "b" $HOME`, "`id`")...).Output()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "a \"b\" $HOME|`id`|")
+}
+
+func (s *MySuite) TestExecuteRetriesAndCapturesOutput(c *C) {
+	counter := path.Join(c.MkDir(), "counter")
+
+	var output bytes.Buffer
+	execution, err := Execute(Command{
+		Name:    "PostProcessingHook",
+		Command: "echo attempt >> " + counter + "; echo try; [ $(wc -l < " + counter + ") -ge 3 ]",
+		Retries: 3,
+	}, &output)
+
+	c.Assert(err, IsNil)
+	c.Assert(execution.Success(), Equals, true)
+	c.Assert(execution.Attempts, Equals, 3)
+	c.Assert(execution.ExitStatus, Equals, 0)
+	c.Assert(output.String(), Equals, strings.Repeat("[PostProcessingHook] try\n", 3))
+}
+
+func (s *MySuite) TestExecuteTimeout(c *C) {
+	summary := &Summary{}
+
+	started := time.Now()
+	execution, err := Execute(Command{Name: "slow", Command: "sleep 10 & wait", Timeout: 200 * time.Millisecond}, nil)
+	summary.Add(execution)
+
+	c.Assert(time.Since(started) < 5*time.Second, Equals, true)
+	c.Assert(err, ErrorMatches, "timed out after 200ms")
+	c.Assert(execution.TimedOut, Equals, true)
+
+	var printed bytes.Buffer
+	summary.Print(&printed)
+	c.Assert(printed.String(), Matches, "  slow +timed out .*1 attempt\\(s\\)\n")
+}
+
+func (s *MySuite) TestRunnerRecordsSummary(c *C) {
+	summary := &Summary{}
+	runner := Runner{
+		Handlers: map[string][]Handler{
+			SeriesAdded: {{Command: "true"}, {Command: "exit 4", Retries: 1}},
+		},
+		Summary: summary,
+	}
+
+	c.Assert(runner.Run(SeriesAdded, Payload{}), IsNil)
+
+	executions := summary.Executions()
+	c.Assert(executions, HasLen, 2)
+	c.Assert(executions[0].Name, Equals, "series.added #1")
+	c.Assert(executions[1].ExitStatus, Equals, 4)
+	c.Assert(executions[1].Attempts, Equals, 2)
+}
@@ -0,0 +1,242 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SignatureHeader contains `sha256=<hex HMAC of the body>` when the webhook
+// has a secret
+const SignatureHeader = "X-Series-Signature"
+
+// EventHeader contains the name of the event
+const EventHeader = "X-Series-Event"
+
+// Webhook receives the payloads of events as JSON POST requests
+type Webhook struct {
+	URL string
+
+	// Secret is used to sign the body, see SignatureHeader
+	Secret string `json:",omitempty"`
+
+	// Events the webhook is notified about, all events when empty
+	Events []string `json:",omitempty"`
+
+	Retries int `json:",omitempty"`
+}
+
+func (w Webhook) subscribed(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+
+	for _, subscribed := range w.Events {
+		if subscribed == event {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateWebhooks checks that the webhooks have an URL and only subscribe
+// to known events
+func ValidateWebhooks(webhooks []Webhook) error {
+	for _, webhook := range webhooks {
+		if webhook.URL == "" {
+			return errors.New("webhook without URL")
+		}
+
+		for _, event := range webhook.Events {
+			if !isEvent(event) {
+				return errors.New(fmt.Sprintf("unknown event '%s' for webhook %s", event, webhook.URL))
+			}
+		}
+	}
+
+	return nil
+}
+
+// Notifier posts events to webhooks
+type Notifier struct {
+	Webhooks []Webhook
+	Client   *http.Client
+
+	// Backoff is the delay before the first retry, it is doubled for each
+	// further retry
+	Backoff time.Duration
+
+	// MaxDuration limits the time spent on notifying a single webhook about
+	// an event, including all retries
+	MaxDuration time.Duration
+
+	start   sync.Once
+	queue   chan notification
+	pending sync.WaitGroup
+}
+
+// notification is an event waiting to be posted in the background
+type notification struct {
+	event  string
+	body   []byte
+	report func(error)
+}
+
+func NewNotifier(webhooks []Webhook) *Notifier {
+	return &Notifier{
+		Webhooks:    webhooks,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		Backoff:     time.Second,
+		MaxDuration: 15 * time.Second,
+	}
+}
+
+// Notify posts the payload to all webhooks subscribed to the event and
+// returns an error for each webhook that could not be notified
+func (n *Notifier) Notify(event string, payload Payload) []error {
+	body, err := json.Marshal(payloadWithEvent(event, payload))
+	if err != nil {
+		return []error{err}
+	}
+
+	return n.notify(event, body)
+}
+
+// NotifyInBackground posts the payload like Notify without waiting for the
+// webhooks. The events are posted one after another in the order they have
+// been passed, report is called for each webhook that could not be notified.
+func (n *Notifier) NotifyInBackground(event string, payload Payload, report func(error)) {
+	// the payload is encoded right away, as it may be changed afterwards
+	body, err := json.Marshal(payloadWithEvent(event, payload))
+	if err != nil {
+		report(err)
+		return
+	}
+
+	n.start.Do(func() {
+		n.queue = make(chan notification, 100)
+		go n.deliver()
+	})
+
+	n.pending.Add(1)
+	n.queue <- notification{event: event, body: body, report: report}
+}
+
+func (n *Notifier) deliver() {
+	for queued := range n.queue {
+		for _, err := range n.notify(queued.event, queued.body) {
+			queued.report(err)
+		}
+		n.pending.Done()
+	}
+}
+
+// Wait waits until the events passed to NotifyInBackground have been posted
+// and returns false when this takes longer than the timeout
+func (n *Notifier) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		n.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (n *Notifier) notify(event string, body []byte) []error {
+	var errs []error
+
+	for _, webhook := range n.Webhooks {
+		if !webhook.subscribed(event) {
+			continue
+		}
+
+		if err := n.post(webhook, event, body); err != nil {
+			errs = append(errs, errors.New(fmt.Sprintf("webhook %s: %s", webhook.URL, err)))
+		}
+	}
+
+	return errs
+}
+
+func (n *Notifier) post(webhook Webhook, event string, body []byte) error {
+	backoff := n.Backoff
+
+	ctx := context.Background()
+	if n.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, n.MaxDuration)
+		defer cancel()
+	}
+
+	var err error
+	for attempt := 0; attempt <= webhook.Retries; attempt++ {
+		if attempt > 0 {
+			// no retry is started which could not finish in time
+			if deadline, ok := ctx.Deadline(); ok && time.Now().Add(backoff).After(deadline) {
+				return errors.New(fmt.Sprintf("%s, giving up after %s", err, n.MaxDuration))
+			}
+
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		var retry bool
+		if retry, err = n.postOnce(ctx, webhook, event, body); err == nil || !retry {
+			return err
+		}
+	}
+
+	return err
+}
+
+// postOnce returns whether a failed request should be retried
+func (n *Notifier) postOnce(ctx context.Context, webhook Webhook, event string, body []byte) (bool, error) {
+	request, err := http.NewRequest("POST", webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request = request.WithContext(ctx)
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, event)
+	if webhook.Secret != "" {
+		request.Header.Set(SignatureHeader, Sign(webhook.Secret, body))
+	}
+
+	response, err := n.Client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests
+	return retry, errors.New(fmt.Sprintf("unexpected status %s", response.Status))
+}
+
+// Sign returns the value of the SignatureHeader for the body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
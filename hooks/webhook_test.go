@@ -0,0 +1,155 @@
+package hooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+type receivedRequest struct {
+	event, signature string
+	body             []byte
+}
+
+type receiver struct {
+	mutex    sync.Mutex
+	statuses []int
+	requests []receivedRequest
+}
+
+// ServeHTTP answers with the next configured status, 200 when none is left
+func (r *receiver) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	body, _ := ioutil.ReadAll(request.Body)
+	r.requests = append(r.requests, receivedRequest{
+		event:     request.Header.Get(EventHeader),
+		signature: request.Header.Get(SignatureHeader),
+		body:      body,
+	})
+
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func newTestNotifier(webhooks ...Webhook) *Notifier {
+	notifier := NewNotifier(webhooks)
+	notifier.Backoff = time.Millisecond
+	return notifier
+}
+
+func (s *MySuite) TestWebhookPayloadAndSignature(c *C) {
+	r := &receiver{}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	notifier := newTestNotifier(Webhook{URL: server.URL, Secret: "geheim"})
+	errs := notifier.Notify(EpisodeRenamed, Payload{"series": "Shameless US", "season": 1})
+	c.Assert(errs, HasLen, 0)
+
+	c.Assert(r.requests, HasLen, 1)
+	c.Assert(r.requests[0].event, Equals, EpisodeRenamed)
+	c.Assert(r.requests[0].signature, Equals, Sign("geheim", r.requests[0].body))
+
+	var payload map[string]interface{}
+	c.Assert(json.Unmarshal(r.requests[0].body, &payload), IsNil)
+	c.Assert(payload, DeepEquals, map[string]interface{}{
+		"event": EpisodeRenamed, "series": "Shameless US", "season": float64(1)})
+}
+
+func (s *MySuite) TestWebhookRetries(c *C) {
+	r := &receiver{statuses: []int{500, 503}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	errs := newTestNotifier(Webhook{URL: server.URL, Retries: 2}).Notify(IndexWritten, Payload{})
+	c.Assert(errs, HasLen, 0)
+	c.Assert(r.requests, HasLen, 3)
+	c.Assert(r.requests[0].signature, Equals, "")
+}
+
+func (s *MySuite) TestWebhookFailures(c *C) {
+	r := &receiver{statuses: []int{500, 500, 400}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	errs := newTestNotifier(Webhook{URL: server.URL, Retries: 1}).Notify(IndexWritten, Payload{})
+	c.Assert(errs, HasLen, 1)
+	c.Assert(errs[0], ErrorMatches, "webhook .*: unexpected status 500 Internal Server Error")
+	c.Assert(r.requests, HasLen, 2)
+
+	// client errors are not retried
+	errs = newTestNotifier(Webhook{URL: server.URL, Retries: 3}).Notify(IndexWritten, Payload{})
+	c.Assert(errs, HasLen, 1)
+	c.Assert(r.requests, HasLen, 3)
+}
+
+func (s *MySuite) TestWebhookEventSubscription(c *C) {
+	r := &receiver{}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	notifier := newTestNotifier(Webhook{URL: server.URL, Events: []string{StreamsNewEpisodes}})
+	c.Assert(notifier.Notify(EpisodeRenamed, Payload{}), HasLen, 0)
+	c.Assert(notifier.Notify(StreamsNewEpisodes, Payload{"count": 2}), HasLen, 0)
+
+	c.Assert(r.requests, HasLen, 1)
+	c.Assert(r.requests[0].event, Equals, StreamsNewEpisodes)
+}
+
+func (s *MySuite) TestWebhookMaxDuration(c *C) {
+	r := &receiver{statuses: []int{500, 500, 500, 500, 500, 500}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	notifier := newTestNotifier(Webhook{URL: server.URL, Retries: 5})
+	notifier.Backoff = 20 * time.Millisecond
+	notifier.MaxDuration = 50 * time.Millisecond
+
+	errs := notifier.Notify(IndexWritten, Payload{})
+	c.Assert(errs, HasLen, 1)
+	c.Assert(errs[0], ErrorMatches, "webhook .*: unexpected status 500 .*, giving up after 50ms")
+	c.Assert(r.requests, HasLen, 2)
+}
+
+func (s *MySuite) TestWebhookNotifyInBackground(c *C) {
+	r := &receiver{statuses: []int{400}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	var mutex sync.Mutex
+	var errs []error
+	report := func(err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		errs = append(errs, err)
+	}
+
+	notifier := newTestNotifier(Webhook{URL: server.URL})
+	payload := Payload{"series": "Shameless US"}
+	notifier.NotifyInBackground(EpisodeRenamed, payload, report)
+	notifier.NotifyInBackground(IndexWritten, Payload{}, report)
+	payload["series"] = "changed"
+
+	c.Assert(notifier.Wait(time.Second), Equals, true)
+	c.Assert(errs, HasLen, 1)
+	c.Assert(r.requests, HasLen, 2)
+	c.Assert(r.requests[0].event, Equals, EpisodeRenamed)
+	c.Assert(string(r.requests[0].body), Matches, `.*"Shameless US".*`)
+	c.Assert(r.requests[1].event, Equals, IndexWritten)
+}
+
+func (s *MySuite) TestValidateWebhooks(c *C) {
+	c.Assert(ValidateWebhooks([]Webhook{{URL: "http://localhost", Events: []string{EpisodeRenamed}}}), IsNil)
+	c.Assert(ValidateWebhooks([]Webhook{{}}), ErrorMatches, "webhook without URL")
+	c.Assert(ValidateWebhooks([]Webhook{{URL: "http://localhost", Events: []string{"renamed"}}}),
+		ErrorMatches, "unknown event 'renamed' .*")
+}
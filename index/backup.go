@@ -0,0 +1,182 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pboehm/series/util"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const backupTimeFormat = "20060102-150405"
+
+var backupFilePattern = regexp.MustCompile("^index-(?P<id>(?P<time>\\d{8}-\\d{6})(-(?P<sequence>\\d+))?)\\.xml$")
+
+// BackupPolicy defines where backups of the index are stored and how many of
+// them are kept. Besides the last Keep backups, the latest backup of each of
+// the last KeepDaily days is retained. The backup at Retain is never removed,
+// e.g. the one being restored.
+type BackupPolicy struct {
+	Directory       string
+	Keep, KeepDaily int
+	Retain          string
+}
+
+func (p BackupPolicy) Enabled() bool {
+	return p.Directory != "" && (p.Keep > 0 || p.KeepDaily > 0)
+}
+
+type Backup struct {
+	Id       string
+	Path     string
+	Time     time.Time
+	sequence int
+}
+
+// CreateBackup copies the index file into the backup directory and removes
+// backups which are not retained by the policy anymore
+func CreateBackup(xmlPath string, policy BackupPolicy) (*Backup, error) {
+	if !policy.Enabled() || !util.PathExists(xmlPath) {
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(policy.Directory, 0755); err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadFile(xmlPath)
+	if err != nil {
+		return nil, err
+	}
+
+	backups, err := ListBackups(policy.Directory)
+	if err != nil {
+		return nil, err
+	}
+
+	// backups created within the same second get an increasing sequence number
+	now := time.Now()
+	backup := &Backup{Id: now.Format(backupTimeFormat), Time: now}
+	for _, existing := range backups {
+		if existing.Time.Format(backupTimeFormat) == backup.Id && existing.sequence >= backup.sequence {
+			backup.sequence = existing.sequence + 1
+		}
+	}
+	if backup.sequence > 0 {
+		backup.Id = fmt.Sprintf("%s-%d", backup.Id, backup.sequence)
+	}
+
+	backup.Path = path.Join(policy.Directory, fmt.Sprintf("index-%s.xml", backup.Id))
+	if err = ioutil.WriteFile(backup.Path, content, 0644); err != nil {
+		return nil, err
+	}
+
+	backups = append(backups, *backup)
+	sortBackups(backups)
+
+	for _, obsolete := range obsoleteBackups(backups, policy) {
+		if obsolete.Path == policy.Retain {
+			continue
+		}
+		if err = os.Remove(obsolete.Path); err != nil {
+			return backup, err
+		}
+	}
+
+	return backup, nil
+}
+
+// ListBackups returns all backups in the directory, the newest first
+func ListBackups(directory string) ([]Backup, error) {
+	if !util.IsDirectory(directory) {
+		return []Backup{}, nil
+	}
+
+	entries, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []Backup{}
+	for _, entry := range entries {
+		groups, matched := util.NamedCaptureGroups(backupFilePattern, entry.Name())
+		if !matched {
+			continue
+		}
+
+		backupTime, err := time.ParseInLocation(backupTimeFormat, groups["time"], time.Local)
+		if err != nil {
+			continue
+		}
+
+		sequence, _ := strconv.Atoi(groups["sequence"])
+
+		backups = append(backups, Backup{
+			Id:       groups["id"],
+			Path:     path.Join(directory, entry.Name()),
+			Time:     backupTime,
+			sequence: sequence,
+		})
+	}
+
+	sortBackups(backups)
+	return backups, nil
+}
+
+// FindBackup returns the backup with the supplied id
+func FindBackup(directory string, id string) (*Backup, error) {
+	backups, err := ListBackups(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, backup := range backups {
+		if backup.Id == id {
+			return &backup, nil
+		}
+	}
+
+	return nil, errors.New(fmt.Sprintf("backup '%s' does not exist", id))
+}
+
+func sortBackups(backups []Backup) {
+	sort.Slice(backups, func(i, j int) bool {
+		iTime := backups[i].Time.Truncate(time.Second)
+		jTime := backups[j].Time.Truncate(time.Second)
+		if !iTime.Equal(jTime) {
+			return iTime.After(jTime)
+		}
+		return backups[i].sequence > backups[j].sequence
+	})
+}
+
+// obsoleteBackups returns the backups, sorted newest first, that are neither
+// one of the last Keep backups nor the latest backup of one of the last
+// KeepDaily days
+func obsoleteBackups(backups []Backup, policy BackupPolicy) []Backup {
+	var obsolete []Backup
+	days := map[string]bool{}
+
+	for i, backup := range backups {
+		day := backup.Time.Format("20060102")
+		newestOfDay := !days[day]
+
+		if newestOfDay && len(days) < policy.KeepDaily {
+			days[day] = true
+			continue
+		}
+
+		if i < policy.Keep {
+			continue
+		}
+
+		obsolete = append(obsolete, backup)
+	}
+
+	return obsolete
+}
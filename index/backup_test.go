@@ -0,0 +1,85 @@
+package index
+
+import (
+	"github.com/pboehm/series/util"
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"path"
+	"time"
+)
+
+func backupsAt(times ...string) []Backup {
+	var backups []Backup
+	for _, t := range times {
+		parsed, _ := time.Parse("2006-01-02 15:04", t)
+		backups = append(backups, Backup{Id: t, Time: parsed})
+	}
+	sortBackups(backups)
+	return backups
+}
+
+func backupIds(backups []Backup) []string {
+	ids := []string{}
+	for _, backup := range backups {
+		ids = append(ids, backup.Id)
+	}
+	return ids
+}
+
+func (s *MySuite) TestObsoleteBackupsKeepsLastN(c *C) {
+	backups := backupsAt("2019-03-10 10:00", "2019-03-10 11:00", "2019-03-10 12:00", "2019-03-10 13:00")
+
+	obsolete := obsoleteBackups(backups, BackupPolicy{Keep: 2})
+	c.Assert(backupIds(obsolete), DeepEquals, []string{"2019-03-10 11:00", "2019-03-10 10:00"})
+}
+
+func (s *MySuite) TestObsoleteBackupsKeepsDailySnapshots(c *C) {
+	backups := backupsAt(
+		"2019-03-08 09:00", "2019-03-08 18:00",
+		"2019-03-09 09:00", "2019-03-09 18:00",
+		"2019-03-10 09:00", "2019-03-10 12:00", "2019-03-10 18:00")
+
+	obsolete := obsoleteBackups(backups, BackupPolicy{Keep: 2, KeepDaily: 2})
+	c.Assert(backupIds(obsolete), DeepEquals, []string{
+		"2019-03-10 09:00", "2019-03-09 09:00", "2019-03-08 18:00", "2019-03-08 09:00"})
+}
+
+func (s *MySuite) TestCreateListAndRetainBackups(c *C) {
+	indexFile := path.Join(s.dir, "index.xml")
+	policy := BackupPolicy{Directory: path.Join(s.dir, "backups"), Keep: 2}
+
+	backup, err := CreateBackup(indexFile, policy)
+	c.Assert(err, IsNil)
+	c.Assert(backup, IsNil)
+
+	for _, content := range []string{"first", "second", "third"} {
+		createFile(indexFile, content)
+		_, err = CreateBackup(indexFile, policy)
+		c.Assert(err, IsNil)
+	}
+
+	backups, err := ListBackups(policy.Directory)
+	c.Assert(err, IsNil)
+	c.Assert(backups, HasLen, 2)
+
+	content, _ := ioutil.ReadFile(backups[0].Path)
+	c.Assert(string(content), Equals, "third")
+
+	found, err := FindBackup(policy.Directory, backups[1].Id)
+	c.Assert(err, IsNil)
+	c.Assert(found.Path, Equals, backups[1].Path)
+
+	_, err = FindBackup(policy.Directory, "20190101-000000")
+	c.Assert(err, ErrorMatches, "backup '20190101-000000' does not exist")
+
+	// the backup being restored is kept although only the last two are retained
+	createFile(indexFile, "current")
+	policy.Retain = found.Path
+	_, err = CreateBackup(indexFile, policy)
+	c.Assert(err, IsNil)
+
+	c.Assert(util.PathExists(found.Path), Equals, true)
+	backups, _ = ListBackups(policy.Directory)
+	c.Assert(backups, HasLen, 3)
+	c.Assert(backups[2].Path, Equals, found.Path)
+}
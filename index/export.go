@@ -0,0 +1,391 @@
+package index
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pboehm/series/renamer"
+	"io"
+	"strconv"
+)
+
+// The kinds of records an exported index consists of
+const (
+	RecordSeries   = "series"
+	RecordAlias    = "alias"
+	RecordLanguage = "language"
+	RecordRange    = "range"
+	RecordEpisode  = "episode"
+)
+
+var recordHeader = []string{"kind", "series", "status", "alias", "language", "from", "to", "episode", "all_before", "title"}
+
+// Record is one row of an exported index. Every series is exported as one
+// series record followed by records for its aliases, languages, watched ranges
+// and episodes.
+type Record struct {
+	Kind      string `json:"kind"`
+	Series    string `json:"series"`
+	Status    string `json:"status,omitempty"`
+	Alias     string `json:"alias,omitempty"`
+	Language  string `json:"language,omitempty"`
+	From      string `json:"from,omitempty"`
+	To        string `json:"to,omitempty"`
+	Episode   string `json:"episode,omitempty"`
+	AllBefore bool   `json:"all_before,omitempty"`
+	Title     string `json:"title,omitempty"`
+}
+
+// Conflict describes a record that could not be merged into the index because
+// it contradicts existing data. The existing data is always kept.
+type Conflict struct {
+	Record Record
+	Reason string
+}
+
+func (c Conflict) String() string {
+	switch c.Record.Kind {
+	case RecordAlias:
+		return fmt.Sprintf("%s: alias '%s': %s", c.Record.Series, c.Record.Alias, c.Reason)
+	case RecordRange:
+		return fmt.Sprintf("%s [%s]: range %s: %s", c.Record.Series, c.Record.Language,
+			Range{c.Record.From, c.Record.To}, c.Reason)
+	case RecordEpisode:
+		return fmt.Sprintf("%s [%s]: %s: %s", c.Record.Series, c.Record.Language, c.Record.Episode, c.Reason)
+	default:
+		return fmt.Sprintf("%s: %s", c.Record.Series, c.Reason)
+	}
+}
+
+// ImportReport summarizes the changes done by ImportRecords
+type ImportReport struct {
+	Series, Aliases, Languages, Ranges, Episodes int
+	Conflicts                                    []Conflict
+}
+
+// ExportRecords converts the whole index into a flat list of records
+func (s *SeriesIndex) ExportRecords() []Record {
+	var records []Record
+
+	for _, series := range s.SeriesList {
+		records = append(records, Record{Kind: RecordSeries, Series: series.Name, Status: series.Status})
+
+		for _, alias := range series.Aliases {
+			records = append(records, Record{Kind: RecordAlias, Series: series.Name, Alias: alias.To})
+		}
+
+		for _, set := range series.EpisodeSets {
+			records = append(records, Record{Kind: RecordLanguage, Series: series.Name, Language: set.GetLanguage()})
+		}
+
+		for _, set := range series.EpisodeSets {
+			for _, r := range set.Ranges {
+				records = append(records, Record{
+					Kind:     RecordRange,
+					Series:   series.Name,
+					Language: set.GetLanguage(),
+					From:     r.From,
+					To:       r.To,
+				})
+			}
+		}
+
+		for _, set := range series.EpisodeSets {
+			for _, episode := range set.EpisodeList {
+				records = append(records, Record{
+					Kind:      RecordEpisode,
+					Series:    series.Name,
+					Language:  set.GetLanguage(),
+					Episode:   episode.Name,
+					AllBefore: episode.AllBefore,
+					Title:     episode.Title,
+				})
+			}
+		}
+	}
+
+	return records
+}
+
+// ImportRecords merges the supplied records into the index. Series, aliases,
+// languages and episodes that do not exist yet are added, contradicting
+// records are reported as conflicts.
+func (s *SeriesIndex) ImportRecords(records []Record) *ImportReport {
+	report := &ImportReport{}
+
+	for _, record := range records {
+		if record.Series == "" {
+			report.Conflicts = append(report.Conflicts, Conflict{record, "record has no series"})
+			continue
+		}
+
+		series, created, conflict := s.importSeries(record, report)
+		if conflict != "" {
+			report.Conflicts = append(report.Conflicts, Conflict{record, conflict})
+			continue
+		}
+
+		switch record.Kind {
+		case RecordSeries:
+			conflict = s.importStatus(series, created, record)
+		case RecordAlias:
+			conflict = s.importAlias(series, record, report)
+		case RecordLanguage:
+			s.importLanguage(series, record.Language, report)
+		case RecordRange:
+			conflict = s.importRange(series, record, report)
+		case RecordEpisode:
+			conflict = s.importEpisode(series, record, report)
+		default:
+			conflict = fmt.Sprintf("unknown record kind '%s'", record.Kind)
+		}
+
+		if conflict != "" {
+			report.Conflicts = append(report.Conflicts, Conflict{record, conflict})
+		}
+	}
+
+	return report
+}
+
+func (s *SeriesIndex) importSeries(record Record, report *ImportReport) (*Series, bool, string) {
+	series, existing := s.seriesMap[record.Series]
+	if existing {
+		if series.Name != record.Series {
+			return nil, false, fmt.Sprintf("name is already an alias of '%s'", series.Name)
+		}
+		return series, false, ""
+	}
+
+	report.Series += 1
+
+	return s.addEmptySeries(record.Series), true, ""
+}
+
+func (s *SeriesIndex) importStatus(series *Series, created bool, record Record) string {
+	imported := Series{Status: record.Status}
+	if imported.GetStatus() == series.GetStatus() {
+		return ""
+	}
+
+	if created {
+		if err := s.SetSeriesStatus(series.Name, imported.GetStatus()); err != nil {
+			return err.Error()
+		}
+		return ""
+	}
+
+	return fmt.Sprintf("status differs (%s != %s)", series.GetStatus(), imported.GetStatus())
+}
+
+func (s *SeriesIndex) importAlias(series *Series, record Record, report *ImportReport) string {
+	other, existing := s.seriesMap[record.Alias]
+	if existing {
+		if other != series {
+			return fmt.Sprintf("already points to '%s'", other.Name)
+		}
+		return ""
+	}
+
+	series.Aliases = append(series.Aliases, Alias{To: record.Alias})
+	s.BuildUpSeriesMap()
+	report.Aliases += 1
+
+	return ""
+}
+
+func (s *SeriesIndex) importLanguage(series *Series, language string, report *ImportReport) *EpisodeSet {
+	set, created := series.ensureEpisodeSet(language)
+	if created {
+		report.Languages += 1
+	}
+
+	return set
+}
+
+func (s *SeriesIndex) importRange(series *Series, record Record, report *ImportReport) string {
+	imported := Range{From: record.From, To: record.To}
+	if _, _, err := imported.bounds(); err != nil {
+		return err.Error()
+	}
+
+	set := s.importLanguage(series, record.Language, report)
+	if set.addRange(imported) {
+		report.Ranges += 1
+	}
+
+	return ""
+}
+
+func (s *SeriesIndex) importEpisode(series *Series, record Record, report *ImportReport) string {
+	matched := renamer.ExtractEpisodeInformation(record.Episode)
+	if matched == nil {
+		return "episode name does not contain season and episode"
+	}
+
+	set := s.importLanguage(series, record.Language, report)
+
+	nrSeason, _ := strconv.Atoi(matched["season"])
+	nrEpisode, _ := strconv.Atoi(matched["episode"])
+
+	if record.AllBefore && !set.reconcileBarrier(nrSeason, nrEpisode) {
+		// the existing barrier already covers the imported one
+		return ""
+	}
+
+	existingName, existing := set.episodeMap[buildIndexKey(nrSeason, nrEpisode)]
+	if existing {
+		if record.AllBefore {
+			// the episode itself is already known, so it becomes the new barrier
+			for i := range set.EpisodeList {
+				if set.EpisodeList[i].Name == existingName {
+					set.EpisodeList[i].AllBefore = true
+				}
+			}
+			set.BuildUpEpisodeMap()
+			return s.importTitle(set, existingName, record.Title)
+		}
+
+		if existingName != record.Episode {
+			return fmt.Sprintf("already indexed as '%s'", existingName)
+		}
+		return s.importTitle(set, existingName, record.Title)
+	}
+
+	set.EpisodeList = append(set.EpisodeList, Episode{Name: record.Episode, AllBefore: record.AllBefore,
+		Title: record.Title})
+	set.BuildUpEpisodeMap()
+	report.Episodes += 1
+
+	return ""
+}
+
+// importTitle sets the title of an indexed episode which has none yet
+func (s *SeriesIndex) importTitle(set *EpisodeSet, episodeName, title string) string {
+	if title == "" {
+		return ""
+	}
+
+	for i := range set.EpisodeList {
+		if set.EpisodeList[i].Name != episodeName {
+			continue
+		}
+
+		if set.EpisodeList[i].Title == "" {
+			set.EpisodeList[i].Title = title
+		} else if set.EpisodeList[i].Title != title {
+			return fmt.Sprintf("already has the title '%s'", set.EpisodeList[i].Title)
+		}
+	}
+
+	return ""
+}
+
+// WriteRecordsCSV writes the records as CSV including a header line
+func WriteRecordsCSV(w io.Writer, records []Record) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(recordHeader); err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		row := []string{
+			record.Kind, record.Series, record.Status, record.Alias, record.Language,
+			record.From, record.To, record.Episode, strconv.FormatBool(record.AllBefore), record.Title,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ReadRecordsCSV reads records written by WriteRecordsCSV. Columns are
+// identified by the header line, so their order does not matter.
+func ReadRecordsCSV(r io.Reader) ([]Record, error) {
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[name] = i
+	}
+
+	for _, name := range []string{"kind", "series"} {
+		if _, ok := columns[name]; !ok {
+			return nil, errors.New(fmt.Sprintf("column '%s' is missing", name))
+		}
+	}
+
+	var records []Record
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		value := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(row) {
+				return ""
+			}
+			return row[i]
+		}
+
+		allBefore := false
+		if raw := value("all_before"); raw != "" {
+			if allBefore, err = strconv.ParseBool(raw); err != nil {
+				return nil, errors.New(fmt.Sprintf("invalid all_before value '%s'", raw))
+			}
+		}
+
+		records = append(records, Record{
+			Kind:      value("kind"),
+			Series:    value("series"),
+			Status:    value("status"),
+			Alias:     value("alias"),
+			Language:  value("language"),
+			From:      value("from"),
+			To:        value("to"),
+			Episode:   value("episode"),
+			AllBefore: allBefore,
+			Title:     value("title"),
+		})
+	}
+
+	return records, nil
+}
+
+func WriteRecordsJSON(w io.Writer, records []Record) error {
+	if records == nil {
+		records = []Record{}
+	}
+
+	bytes, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(bytes, '\n'))
+	return err
+}
+
+func ReadRecordsJSON(r io.Reader) ([]Record, error) {
+	var records []Record
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
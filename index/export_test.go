@@ -0,0 +1,94 @@
+package index
+
+import (
+	"bytes"
+	. "launchpad.net/gocheck"
+)
+
+func (s *MySuite) TestExportImportRoundTripCSV(c *C) {
+	c.Assert(s.index.SetSeriesStatus("Community", StatusEnded), IsNil)
+	c.Assert(s.index.SetEpisodeTitle("Shameless US", "de", 1, 1, "Pilotfolge, Teil 1"), IsNil)
+	records := s.index.ExportRecords()
+
+	var buffer bytes.Buffer
+	c.Assert(WriteRecordsCSV(&buffer, records), IsNil)
+
+	parsed, err := ReadRecordsCSV(&buffer)
+	c.Assert(err, IsNil)
+	c.Assert(parsed, DeepEquals, records)
+
+	imported := &SeriesIndex{}
+	report := imported.ImportRecords(parsed)
+	c.Assert(report.Conflicts, HasLen, 0)
+	c.Assert(report.Series, Equals, 4)
+	c.Assert(imported.ExportRecords(), DeepEquals, records)
+	c.Assert(imported.SeriesStatus("Community"), Equals, StatusEnded)
+
+	title, ok := imported.EpisodeTitle("Shameless US", "de", 1, 1)
+	c.Assert(ok, Equals, true)
+	c.Assert(title, Equals, "Pilotfolge, Teil 1")
+}
+
+func (s *MySuite) TestImportTitleOfExistingEpisode(c *C) {
+	records := s.index.ExportRecords()
+	for i := range records {
+		if records[i].Kind == RecordEpisode && records[i].Series == "Shameless US" {
+			records[i].Title = "Pilotfolge"
+			break
+		}
+	}
+
+	report := s.index.ImportRecords(records)
+	c.Assert(report.Conflicts, HasLen, 0)
+	c.Assert(report.Episodes, Equals, 0)
+
+	title, _ := s.index.EpisodeTitle("Shameless US", "de", 1, 1)
+	c.Assert(title, Equals, "Pilotfolge")
+}
+
+func (s *MySuite) TestExportImportRoundTripJSON(c *C) {
+	records := s.index.ExportRecords()
+
+	var buffer bytes.Buffer
+	c.Assert(WriteRecordsJSON(&buffer, records), IsNil)
+
+	parsed, err := ReadRecordsJSON(&buffer)
+	c.Assert(err, IsNil)
+	c.Assert(parsed, DeepEquals, records)
+
+	imported := &SeriesIndex{}
+	report := imported.ImportRecords(parsed)
+	c.Assert(report.Conflicts, HasLen, 0)
+	c.Assert(imported.ExportRecords(), DeepEquals, records)
+}
+
+func (s *MySuite) TestImportIntoExistingIndexIsIdempotent(c *C) {
+	report := s.index.ImportRecords(s.index.ExportRecords())
+
+	c.Assert(report.Conflicts, HasLen, 0)
+	c.Assert(report.Series, Equals, 0)
+	c.Assert(report.Aliases, Equals, 0)
+	c.Assert(report.Languages, Equals, 0)
+	c.Assert(report.Episodes, Equals, 0)
+}
+
+func (s *MySuite) TestImportWithConflicts(c *C) {
+	report := s.index.ImportRecords([]Record{
+		{Kind: RecordAlias, Series: "Shameless US", Alias: "Comm"},
+		{Kind: RecordSeries, Series: "unity"},
+		{Kind: RecordEpisode, Series: "Shameless US", Language: "de", Episode: "S01E01 - Other.avi"},
+		{Kind: RecordEpisode, Series: "Shameless US", Language: "de", Episode: "S01E09 - New.avi"},
+		{Kind: RecordEpisode, Series: "New Series", Language: "en", Episode: "S01E01 - Pilot.avi"},
+	})
+
+	c.Assert(report.Conflicts, HasLen, 3)
+	c.Assert(report.Conflicts[0].Reason, Equals, "already points to 'Community'")
+	c.Assert(report.Conflicts[1].Reason, Equals, "name is already an alias of 'Community'")
+	c.Assert(report.Conflicts[2].Reason, Equals, "already indexed as 'S01E01 - Pilot.avi'")
+
+	c.Assert(report.Series, Equals, 1)
+	c.Assert(report.Languages, Equals, 1)
+	c.Assert(report.Episodes, Equals, 2)
+	c.Assert(s.index.IsEpisodeInIndexManual("New Series", "en", 1, 1), Equals, true)
+	c.Assert(s.index.IsEpisodeInIndexManual("Shameless US", "de", 1, 9), Equals, true)
+}
@@ -14,11 +14,24 @@ import (
 
 var DefaultLanguage = "de"
 
+// The lifecycle states a series can be in. Series without an explicit status
+// are active.
+const (
+	StatusActive  = "active"
+	StatusPaused  = "paused"
+	StatusEnded   = "ended"
+	StatusDropped = "dropped"
+)
+
+var SeriesStatuses = []string{StatusActive, StatusPaused, StatusEnded, StatusDropped}
+
 type SeriesIndex struct {
 	XMLName        xml.Name `xml:"seriesindex"`
+	Version        int      `xml:"version,attr,omitempty"`
 	SeriesList     []Series `xml:"series"`
 	seriesMap      map[string]*Series
 	nameExtractors []SeriesNameExtractor
+	titleProviders []TitleProvider
 }
 
 // AddExtractor adds another SeriesNameExtractor for generating possible series
@@ -27,39 +40,30 @@ func (s *SeriesIndex) AddExtractor(ex SeriesNameExtractor) {
 	s.nameExtractors = append(s.nameExtractors, ex)
 }
 
-func (s *SeriesIndex) AddEpisode(episode *renamer.Episode) (bool, error) {
-
-	// test for all possible series names if they exist in index and take the
-	// first matching
-ExtractorLoop:
-	for _, extractor := range s.nameExtractors {
-		names, err := extractor.Names(episode)
-
-		if err != nil {
-			fmt.Printf("!!! Error asking extractor for series names: %s", err)
-			continue
-		}
-
-		for _, possibleSeries := range names {
-			seriesName := s.SeriesNameInIndex(possibleSeries)
-			if seriesName != "" {
-				episode.Series = seriesName
-				break ExtractorLoop
-			}
-		}
+// AddEpisode adds the episode to the index. The returned MatchResult
+// describes how the series name and the language have been determined.
+func (s *SeriesIndex) AddEpisode(episode *renamer.Episode) (*MatchResult, error) {
+	result, series, err := s.matchEpisode(episode, false)
+	if err != nil {
+		return result, err
 	}
 
-	series, existing := s.seriesMap[episode.Series]
-	if !existing {
-		return false, errors.New("series does not exist in index")
+	if series.GetStatus() == StatusDropped {
+		return result, errors.New("series has been dropped")
 	}
 
-	// Handle episodes where no language is set
-	if episode.Language == "" {
-		s.GuessEpisodeLanguage(episode, series)
+	result.Added, err = s.AddEpisodeManually(episode.Series, episode.Language, episode.Season, episode.Episode, episode.CleanedFileName())
+	if result.Added && !episode.HasDefaultEpisodeName() {
+		err = s.SetEpisodeTitle(episode.Series, episode.Language, episode.Season, episode.Episode, episode.Name)
 	}
 
-	return s.AddEpisodeManually(episode.Series, episode.Language, episode.Season, episode.Episode, episode.CleanedFileName())
+	return result, err
+}
+
+// resolveSeriesName asks all extractors for possible series names and returns
+// the first one that exists in the index or an empty string
+func (s *SeriesIndex) resolveSeriesName(episode *renamer.Episode) string {
+	return s.matchSeriesName(episode, &MatchResult{}, false)
 }
 
 func (s *SeriesIndex) AddEpisodeManually(seriesNameInIndex string, language string, season int, episode int, filename string) (bool, error) {
@@ -99,18 +103,8 @@ func (s *SeriesIndex) AddSeries(seriesname, language string, season int, episode
 	}
 
 	series := Series{
-		Name: seriesname,
-		EpisodeSets: []EpisodeSet{
-			{
-				Language: language,
-				EpisodeList: []Episode{
-					{
-						Name:      fmt.Sprintf("S%02dE%02d - Pre-First.mov", season, episode),
-						AllBefore: true,
-					},
-				},
-			},
-		},
+		Name:        seriesname,
+		EpisodeSets: []EpisodeSet{newEpisodeSet(language, season, episode)},
 	}
 
 	s.SeriesList = append(s.SeriesList, series)
@@ -119,6 +113,84 @@ func (s *SeriesIndex) AddSeries(seriesname, language string, season int, episode
 	return true, nil
 }
 
+// AddLanguage starts watching an existing series in another language. All
+// episodes up to and including the supplied season/episode are treated as
+// already watched.
+func (s *SeriesIndex) AddLanguage(seriesname, language string, season int, episode int) (bool, error) {
+
+	series, existing := s.seriesMap[seriesname]
+	if !existing {
+		return false, errors.New("series does not exist in index")
+	}
+
+	_, languageExist := series.languageMap[language]
+	if languageExist {
+		return false, errors.New("series is already watched in this language")
+	}
+
+	series.EpisodeSets = append(series.EpisodeSets, newEpisodeSet(language, season, episode))
+	s.BuildUpSeriesMap()
+
+	return true, nil
+}
+
+func (s *SeriesIndex) RemoveLanguage(seriesname, language string) (bool, error) {
+
+	series, existing := s.seriesMap[seriesname]
+	if !existing {
+		return false, errors.New("series does not exist in index")
+	}
+
+	_, languageExist := series.languageMap[language]
+	if !languageExist {
+		return false, errors.New("series is not watched in this language")
+	}
+
+	if len(series.EpisodeSets) == 1 {
+		return false, errors.New("series is only watched in this language, remove the series instead")
+	}
+
+	for i := 0; i < len(series.EpisodeSets); i++ {
+		if series.EpisodeSets[i].GetLanguage() == language {
+			series.EpisodeSets = append(
+				series.EpisodeSets[:i],
+				series.EpisodeSets[i+1:]...,
+			)
+			s.BuildUpSeriesMap()
+			return true, nil
+		}
+	}
+
+	return false, errors.New("series is not watched in this language")
+}
+
+// addEmptySeries adds a series without any language or episode to the index
+func (s *SeriesIndex) addEmptySeries(seriesname string) *Series {
+	s.SeriesList = append(s.SeriesList, Series{Name: seriesname})
+	s.BuildUpSeriesMap()
+
+	return s.seriesMap[seriesname]
+}
+
+// preFirstSuffix marks the synthetic episodes that older versions used as
+// all_before barrier when a series or language has been added
+const preFirstSuffix = " - Pre-First.mov"
+
+// newEpisodeSet creates an EpisodeSet where all episodes up to the supplied
+// one are marked as watched
+// newEpisodeSet returns a set with a range up to and including the supplied
+// episode, S01E00 and before mean that nothing has been watched yet
+func newEpisodeSet(language string, season int, episode int) EpisodeSet {
+	set := EpisodeSet{Language: language}
+
+	last := EpisodeNumber{season, episode}
+	if !last.Before(EpisodeNumber{1, 1}) {
+		set.Ranges = []Range{{To: last.String()}}
+	}
+
+	return set
+}
+
 func (s *SeriesIndex) RemoveSeries(seriesname string) (bool, error) {
 
 	series, existing := s.seriesMap[seriesname]
@@ -153,12 +225,100 @@ func (s *SeriesIndex) AliasSeries(seriesname string, alias string) error {
 	}
 
 	series.Aliases = append(series.Aliases, Alias{To: alias})
-	series.BuildUpLanguageMap()
+	s.BuildUpSeriesMap()
+
+	return nil
+}
+
+func (s *SeriesIndex) RemoveAlias(seriesname string, alias string) error {
+
+	series, existing := s.seriesMap[seriesname]
+	if !existing {
+		return errors.New("series does not exist in index")
+	}
+
+	for i := 0; i < len(series.Aliases); i++ {
+		if series.Aliases[i].To == alias {
+			series.Aliases = append(series.Aliases[:i], series.Aliases[i+1:]...)
+			s.BuildUpSeriesMap()
+			return nil
+		}
+	}
+
+	return errors.New("alias does not exist for this series")
+}
+
+// RenameSeries changes the name of the supplied series to newName. When
+// keepAlias is set, the old name is kept as an alias so that episodes released
+// under the old title are still recognized.
+func (s *SeriesIndex) RenameSeries(seriesname string, newName string, keepAlias bool) error {
+
+	series, existing := s.seriesMap[seriesname]
+	if !existing {
+		return errors.New("series does not exist in index")
+	}
+
+	other, otherExisting := s.seriesMap[newName]
+	if otherExisting && other != series {
+		return errors.New("new name does already exist as series in index")
+	}
+
+	// the new name could be an alias of this series, which is not needed anymore
+	for i := 0; i < len(series.Aliases); i++ {
+		if series.Aliases[i].To == newName {
+			series.Aliases = append(series.Aliases[:i], series.Aliases[i+1:]...)
+			break
+		}
+	}
+
+	oldName := series.Name
+	series.Name = newName
+
+	if keepAlias && oldName != newName {
+		series.Aliases = append(series.Aliases, Alias{To: oldName})
+	}
+
+	s.BuildUpSeriesMap()
+
+	return nil
+}
+
+// SetSeriesStatus changes the lifecycle status of the supplied series, which
+// has to be one of SeriesStatuses.
+func (s *SeriesIndex) SetSeriesStatus(seriesname string, status string) error {
+
+	series, existing := s.seriesMap[seriesname]
+	if !existing {
+		return errors.New("series does not exist in index")
+	}
+
+	valid := false
+	for _, known := range SeriesStatuses {
+		if known == status {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return errors.New(fmt.Sprintf("invalid status, use one of: %s",
+			strings.Join(SeriesStatuses, ", ")))
+	}
+
+	// active is the default and does not need to be stored
+	if status == StatusActive {
+		status = ""
+	}
+	series.Status = status
 
 	return nil
 }
 
 func (s *SeriesIndex) GuessEpisodeLanguage(episode *renamer.Episode, series *Series) {
+	s.guessEpisodeLanguage(episode, series)
+}
+
+// guessEpisodeLanguage returns the reason for the chosen language
+func (s *SeriesIndex) guessEpisodeLanguage(episode *renamer.Episode, series *Series) string {
 	// This methods tries to find the right language for the supplied episode
 	// based on several heuristics
 
@@ -169,6 +329,7 @@ func (s *SeriesIndex) GuessEpisodeLanguage(episode *renamer.Episode, series *Ser
 			episode.Language = k
 			break
 		}
+		return "series is only watched in this language"
 	}
 
 	// Find the language which is most likely the right language
@@ -187,6 +348,7 @@ func (s *SeriesIndex) GuessEpisodeLanguage(episode *renamer.Episode, series *Ser
 
 		if len(possibleLanguages) == 1 {
 			episode.Language = possibleLanguages[0]
+			return "episode has not been watched in this language yet"
 
 		} else if len(possibleLanguages) > 1 {
 			// take the language where the previous episode exist
@@ -206,9 +368,12 @@ func (s *SeriesIndex) GuessEpisodeLanguage(episode *renamer.Episode, series *Ser
 
 			if len(previousExisting) == 1 {
 				episode.Language = previousExisting[0]
+				return "previous episode has been watched in this language"
 			}
 		}
 	}
+
+	return "language could not be determined"
 }
 
 func (s *SeriesIndex) SeriesNameInIndex(seriesName string) string {
@@ -267,18 +432,7 @@ func (s *SeriesIndex) IsEpisodeInIndexManual(inputSeriesName string, language st
 		return true
 	}
 
-	// check if episode is before the lowest episode which sets all_before=true
-	// takes place
-	if set.allBefore {
-		barrier := set.allBeforeSeason*100 + set.allBeforeEpisode
-		actual := season*100 + episode
-
-		if actual < barrier {
-			return true
-		}
-	}
-
-	return false
+	return set.covers(EpisodeNumber{season, episode})
 }
 
 func (s *SeriesIndex) SeriesLanguages(seriesNameInIndex string) []string {
@@ -294,6 +448,15 @@ func (s *SeriesIndex) SeriesLanguages(seriesNameInIndex string) []string {
 	return languages
 }
 
+func (s *SeriesIndex) SeriesStatus(seriesNameInIndex string) string {
+	series, ok := s.seriesMap[seriesNameInIndex]
+	if ok {
+		return series.GetStatus()
+	}
+
+	return ""
+}
+
 func ParseSeriesIndex(xmlPath string) (*SeriesIndex, error) {
 	var index SeriesIndex
 
@@ -304,8 +467,17 @@ func ParseSeriesIndex(xmlPath string) (*SeriesIndex, error) {
 	defer xmlFile.Close()
 
 	content, err := ioutil.ReadAll(xmlFile)
+	if err != nil {
+		return &index, err
+	}
 
-	xml.Unmarshal([]byte(content), &index)
+	if err = xml.Unmarshal([]byte(content), &index); err != nil {
+		return &index, err
+	}
+
+	if err = checkVersion(&index); err != nil {
+		return &index, err
+	}
 
 	index.BuildUpSeriesMap()
 	return &index, nil
@@ -328,28 +500,37 @@ func (s *SeriesIndex) BuildUpSeriesMap() {
 	}
 }
 
-func (s *SeriesIndex) WriteToFile(xmlPath string) {
+func (s *SeriesIndex) WriteToFile(xmlPath string) error {
+	if s.Version == 0 {
+		s.Version = CurrentVersion
+	}
 
 	marshaled, err := xml.MarshalIndent(*s, "", "  ")
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	output := append([]byte(xml.Header), marshaled...)
 
-	err = ioutil.WriteFile(xmlPath, output, 0644)
-	if err != nil {
-		panic(err)
-	}
+	return ioutil.WriteFile(xmlPath, output, 0644)
 }
 
 type Series struct {
 	Name        string       `xml:"name,attr"`
 	EpisodeSets []EpisodeSet `xml:"episodes"`
 	Aliases     []Alias      `xml:"alias"`
+	Status      string       `xml:"status,attr,omitempty"`
 	languageMap map[string]*EpisodeSet
 }
 
+func (s *Series) GetStatus() string {
+	if s.Status != "" {
+		return s.Status
+	}
+
+	return StatusActive
+}
+
 func (s *Series) BuildUpLanguageMap() {
 	s.languageMap = make(map[string]*EpisodeSet)
 
@@ -360,17 +541,40 @@ func (s *Series) BuildUpLanguageMap() {
 	}
 }
 
+// ensureEpisodeSet returns the EpisodeSet for the supplied language and creates
+// an empty one if the series is not watched in this language yet
+func (s *Series) ensureEpisodeSet(language string) (*EpisodeSet, bool) {
+	if language == "" {
+		language = DefaultLanguage
+	}
+
+	set, existing := s.languageMap[language]
+	if existing {
+		return set, false
+	}
+
+	s.EpisodeSets = append(s.EpisodeSets, EpisodeSet{Language: language})
+	s.BuildUpLanguageMap()
+
+	return s.languageMap[language], true
+}
+
 type EpisodeSet struct {
-	XMLName                           xml.Name  `xml:"episodes"`
-	EpisodeList                       []Episode `xml:"episode"`
-	Language                          string    `xml:"lang,attr,omitempty"`
-	episodeMap                        map[string]string
-	allBefore                         bool
-	allBeforeSeason, allBeforeEpisode int
+	XMLName     xml.Name  `xml:"episodes"`
+	Ranges      []Range   `xml:"range"`
+	EpisodeList []Episode `xml:"episode"`
+	Language    string    `xml:"lang,attr,omitempty"`
+	episodeMap  map[string]string
+	allBefore   bool
+	barrier     EpisodeNumber
+	ranges      []episodeRange
 }
 
 func (e *EpisodeSet) BuildUpEpisodeMap() {
 	e.episodeMap = make(map[string]string)
+	e.allBefore = false
+	e.barrier = EpisodeNumber{}
+	e.ranges = nil
 
 	for _, episode := range e.EpisodeList {
 
@@ -382,14 +586,69 @@ func (e *EpisodeSet) BuildUpEpisodeMap() {
 
 			e.episodeMap[key] = episode.Name
 
-			// handle all_before flag and set data for later usage
-			if episode.AllBefore {
+			// when there are multiple episodes with all_before=true the latest
+			// one is the effective barrier
+			number := EpisodeNumber{nrSeason, nrEpisode}
+			if episode.AllBefore && (!e.allBefore || e.barrier.Before(number)) {
 				e.allBefore = true
-				e.allBeforeSeason = nrSeason
-				e.allBeforeEpisode = nrEpisode
+				e.barrier = number
 			}
 		}
 	}
+
+	for _, r := range e.Ranges {
+		from, to, err := r.bounds()
+		if err == nil {
+			e.ranges = append(e.ranges, episodeRange{from, to})
+		}
+	}
+}
+
+// covers returns true if the episode is not listed explicitly but is part of a
+// watched range or before the all_before barrier
+func (e *EpisodeSet) covers(number EpisodeNumber) bool {
+	if e.allBefore && number.Before(e.barrier) {
+		return true
+	}
+
+	for _, r := range e.ranges {
+		if r.contains(number) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LastEpisode returns the season and episode number of the latest episode in
+// this set including the end of watched ranges. found is false when the set
+// does not contain any episode.
+func (e *EpisodeSet) LastEpisode() (season int, episode int, found bool) {
+	var last EpisodeNumber
+
+	for _, entry := range e.EpisodeList {
+
+		matched := renamer.ExtractEpisodeInformation(entry.Name)
+		if matched == nil {
+			continue
+		}
+
+		nrSeason, _ := strconv.Atoi(matched["season"])
+		nrEpisode, _ := strconv.Atoi(matched["episode"])
+
+		number := EpisodeNumber{nrSeason, nrEpisode}
+		if !found || last.Before(number) {
+			last, found = number, true
+		}
+	}
+
+	for _, r := range e.ranges {
+		if !found || last.Before(r.to) {
+			last, found = r.to, true
+		}
+	}
+
+	return last.Season, last.Episode, found
 }
 
 func (e *EpisodeSet) GetLanguage() string {
@@ -402,6 +661,7 @@ func (e *EpisodeSet) GetLanguage() string {
 
 type Episode struct {
 	Name      string `xml:"name,attr"`
+	Title     string `xml:"title,attr,omitempty"`
 	AllBefore bool   `xml:"all_before,attr,omitempty"`
 }
 
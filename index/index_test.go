@@ -96,9 +96,9 @@ func (s *MySuite) TestAddValidEpisodeToIndex(c *C) {
 	episode := renamer.Episode{Series: "Shameless US", Season: 1, Episode: 9,
 		Name: "Testepisode", Extension: ".mkv", Language: "de"}
 
-	added, err := s.index.AddEpisode(&episode)
+	result, err := s.index.AddEpisode(&episode)
 	c.Assert(err, IsNil)
-	c.Assert(added, Equals, true)
+	c.Assert(result.Added, Equals, true)
 	c.Assert(s.index.IsEpisodeInIndex(episode), Equals, true)
 }
 
@@ -106,18 +106,18 @@ func (s *MySuite) TestAddAlreadyExistingEpisodeToIndex(c *C) {
 	episode := renamer.Episode{Series: "Shameless US", Season: 1, Episode: 1,
 		Name: "Testepisode", Extension: ".mkv", Language: "de"}
 
-	added, err := s.index.AddEpisode(&episode)
+	result, err := s.index.AddEpisode(&episode)
 	c.Assert(err, ErrorMatches, "episode already exists in index")
-	c.Assert(added, Equals, false)
+	c.Assert(result.Added, Equals, false)
 }
 
 func (s *MySuite) TestAddEpisodeWithoutLanguageToSeriesWithSingleLang(c *C) {
 	episode := renamer.Episode{Series: "The Big Bang Theory", Season: 6,
 		Episode: 5, Name: "Testepisode", Extension: ".mkv"}
 
-	added, err := s.index.AddEpisode(&episode)
+	result, err := s.index.AddEpisode(&episode)
 	c.Assert(err, IsNil)
-	c.Assert(added, Equals, true)
+	c.Assert(result.Added, Equals, true)
 	c.Assert(s.index.IsEpisodeInIndex(episode), Equals, true)
 	c.Assert(episode.Language, Equals, "de")
 }
@@ -127,9 +127,9 @@ func (s *MySuite) TestAddEpisodeWithoutLanguageToSeriesWithMultiLang(c *C) {
 	episode := renamer.Episode{Series: "Shameless US", Season: 1,
 		Episode: 9, Name: "Testepisode", Extension: ".mkv"}
 
-	added, err := s.index.AddEpisode(&episode)
+	result, err := s.index.AddEpisode(&episode)
 	c.Assert(err, IsNil)
-	c.Assert(added, Equals, true)
+	c.Assert(result.Added, Equals, true)
 	c.Assert(s.index.IsEpisodeInIndex(episode), Equals, true)
 	c.Assert(episode.Language, Equals, "de")
 }
@@ -141,9 +141,9 @@ func (s *MySuite) TestAddEpisodeWithoutLanguageToSeriesWithMultiLangPrev(c *C) {
 		Episode: 12, Name: "Testepisode", Extension: ".mkv"}
 
 	c.Assert(s.index.IsEpisodeInIndex(episode), Equals, false)
-	added, err := s.index.AddEpisode(&episode)
+	result, err := s.index.AddEpisode(&episode)
 	c.Assert(err, IsNil)
-	c.Assert(added, Equals, true)
+	c.Assert(result.Added, Equals, true)
 	c.Assert(s.index.IsEpisodeInIndex(episode), Equals, true)
 	c.Assert(episode.Language, Equals, "en")
 }
@@ -153,8 +153,8 @@ func (s *MySuite) TestAddEpisodeWithCrappyFileInfos(c *C) {
 		Series: "tvs tbbt dd51 ded dl 18p ithd avc", Season: 6,
 		Episode: 5, Name: "", Extension: ".mkv"}
 
-	added, err := s.index.AddEpisode(&episode)
-	c.Assert(added, Equals, false)
+	result, err := s.index.AddEpisode(&episode)
+	c.Assert(result.Added, Equals, false)
 	c.Assert(err, NotNil)
 	c.Assert(err, ErrorMatches, "series does not exist in index")
 }
@@ -167,6 +167,15 @@ func (m mockExtractor) Names(*renamer.Episode) ([]string, error) {
 	return m.names, nil
 }
 
+type mockLanguageExtractor struct {
+	mockExtractor
+	language string
+}
+
+func (m mockLanguageExtractor) NamesAndLanguage(*renamer.Episode) ([]string, string, error) {
+	return m.names, m.language, nil
+}
+
 func (s *MySuite) TestSeriesNameExtractor(c *C) {
 	s.index.AddExtractor(mockExtractor{names: []string{
 		"Should-Also-Not-Exist", "Shameless US"}})
@@ -175,12 +184,70 @@ func (s *MySuite) TestSeriesNameExtractor(c *C) {
 		Season: 1, Episode: 9, Name: "Testepisode", Extension: ".mkv",
 		Language: "de"}
 
-	added, err := s.index.AddEpisode(&episode)
+	result, err := s.index.AddEpisode(&episode)
 	c.Assert(err, IsNil)
-	c.Assert(added, Equals, true)
+	c.Assert(result.Added, Equals, true)
 	c.Assert(episode.Series, Equals, "Shameless US")
 }
 
+func (s *MySuite) TestAddEpisodeReportsMatchResult(c *C) {
+	s.index.AddExtractor(mockExtractor{names: []string{"Should-Also-Not-Exist"}})
+	s.index.AddExtractor(mockExtractor{names: []string{"shameless us"}})
+
+	episode := renamer.Episode{Series: "shameless.us.720p", Season: 1,
+		Episode: 9, Name: "Testepisode", Extension: ".mkv"}
+
+	result, err := s.index.AddEpisode(&episode)
+	c.Assert(err, IsNil)
+	c.Assert(result.Added, Equals, true)
+	c.Assert(result.ParsedSeries, Equals, "shameless.us.720p")
+	c.Assert(result.Candidates, HasLen, 2)
+	c.Assert(result.Candidates[0].Names, DeepEquals, []string{"Should-Also-Not-Exist"})
+	c.Assert(result.Series, Equals, "Shameless US")
+	c.Assert(result.MatchedName, Equals, "shameless us")
+	c.Assert(result.Extractor, Equals, "mockExtractor")
+	c.Assert(result.Language, Equals, "de")
+	c.Assert(result.LanguageReason, Equals, "episode has not been watched in this language yet")
+}
+
+func (s *MySuite) TestMatchEpisodeDoesNotChangeIndex(c *C) {
+	s.index.AddExtractor(mockExtractor{names: []string{"Shameless US"}})
+	s.index.AddExtractor(mockExtractor{names: []string{"Community"}})
+
+	episode := renamer.Episode{Series: "unknown", Season: 1, Episode: 9,
+		Name: "Testepisode", Extension: ".mkv", Language: "de"}
+
+	result, err := s.index.MatchEpisode(&episode)
+	c.Assert(err, IsNil)
+	c.Assert(result.Candidates, HasLen, 2)
+	c.Assert(result.Series, Equals, "Shameless US")
+	c.Assert(result.AlreadyInIndex, Equals, false)
+	c.Assert(result.Added, Equals, false)
+	c.Assert(s.index.IsEpisodeInIndex(episode), Equals, false)
+
+	episode = renamer.Episode{Series: "not existing", Season: 1, Episode: 1}
+	s.index.nameExtractors = nil
+	result, err = s.index.MatchEpisode(&episode)
+	c.Assert(err, ErrorMatches, "series does not exist in index")
+	c.Assert(result.Series, Equals, "")
+}
+
+func (s *MySuite) TestLanguageOfMatchingExtractorIsApplied(c *C) {
+	s.index.AddExtractor(mockLanguageExtractor{mockExtractor{[]string{"Not Existing"}}, "fr"})
+	s.index.AddExtractor(mockLanguageExtractor{mockExtractor{[]string{"Shameless US"}}, "en"})
+	s.index.AddExtractor(mockLanguageExtractor{mockExtractor{[]string{"Community"}}, "es"})
+
+	episode := renamer.Episode{Series: "unknown", Season: 1, Episode: 9,
+		Name: "Testepisode", Extension: ".mkv"}
+
+	result, err := s.index.MatchEpisode(&episode)
+	c.Assert(err, IsNil)
+	c.Assert(result.Candidates, HasLen, 3)
+	c.Assert(result.Candidates[0].Language, Equals, "fr")
+	c.Assert(result.Series, Equals, "Shameless US")
+	c.Assert(result.Language, Equals, "en")
+}
+
 func (s *MySuite) TestWriteIndexToFile(c *C) {
 	episode := renamer.Episode{Series: "Shameless US", Season: 1, Episode: 9,
 		Name: "Testepisode", Extension: ".mkv", Language: "de"}
@@ -188,14 +255,14 @@ func (s *MySuite) TestWriteIndexToFile(c *C) {
 	c.Assert(s.index.IsEpisodeInIndex(episode), Equals, false)
 
 	// add episode
-	added, err := s.index.AddEpisode(&episode)
+	result, err := s.index.AddEpisode(&episode)
 	c.Assert(err, IsNil)
-	c.Assert(added, Equals, true)
+	c.Assert(result.Added, Equals, true)
 	c.Assert(s.index.IsEpisodeInIndex(episode), Equals, true)
 
 	// dump it
 	dest := path.Join(s.dir, "seriesindex_dump.xml")
-	s.index.WriteToFile(dest)
+	c.Assert(s.index.WriteToFile(dest), IsNil)
 	c.Assert(util.PathExists(dest), Equals, true)
 
 	// parse it back and make assertions
@@ -203,3 +270,115 @@ func (s *MySuite) TestWriteIndexToFile(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(index.IsEpisodeInIndex(episode), Equals, true)
 }
+
+func (s *MySuite) TestRemoveAlias(c *C) {
+	c.Assert(s.index.SeriesNameInIndex("Comm"), Equals, "Community")
+
+	err := s.index.RemoveAlias("Community", "Comm")
+	c.Assert(err, IsNil)
+	c.Assert(s.index.seriesMap["Community"].Aliases, HasLen, 1)
+	c.Assert(s.index.SeriesNameInIndex("Comm"), Equals, "")
+
+	err = s.index.RemoveAlias("Community", "Comm")
+	c.Assert(err, ErrorMatches, "alias does not exist for this series")
+
+	err = s.index.RemoveAlias("Not Existing", "Comm")
+	c.Assert(err, ErrorMatches, "series does not exist in index")
+}
+
+func (s *MySuite) TestRenameSeries(c *C) {
+	err := s.index.RenameSeries("Community", "Community (2009)", true)
+	c.Assert(err, IsNil)
+	c.Assert(s.index.SeriesNameInIndex("Community (2009)"), Equals, "Community (2009)")
+	c.Assert(s.index.SeriesNameInIndex("Community"), Equals, "Community (2009)")
+	c.Assert(s.index.SeriesNameInIndex("Comm"), Equals, "Community (2009)")
+
+	episode := renamer.Episode{Series: "Community (2009)", Season: 1, Episode: 1,
+		Language: "de"}
+	c.Assert(s.index.IsEpisodeInIndex(episode), Equals, true)
+
+	err = s.index.RenameSeries("Community (2009)", "Shameless US", true)
+	c.Assert(err, ErrorMatches, "new name does already exist as series in index")
+}
+
+func (s *MySuite) TestRenameSeriesToAlias(c *C) {
+	err := s.index.RenameSeries("Community", "Comm", false)
+	c.Assert(err, IsNil)
+
+	series := s.index.seriesMap["Comm"]
+	c.Assert(series.Name, Equals, "Comm")
+	c.Assert(series.Aliases, DeepEquals, []Alias{{To: "unity"}})
+	c.Assert(s.index.SeriesNameInIndex("Community"), Equals, "")
+}
+
+func (s *MySuite) TestAddLanguage(c *C) {
+	added, err := s.index.AddLanguage("Community", "en", 2, 4)
+	c.Assert(err, IsNil)
+	c.Assert(added, Equals, true)
+	c.Assert(s.index.SeriesLanguages("Community"), HasLen, 2)
+
+	episode := renamer.Episode{Series: "Community", Season: 2, Episode: 3,
+		Language: "en"}
+	c.Assert(s.index.IsEpisodeInIndex(episode), Equals, true)
+
+	episode.Episode = 5
+	c.Assert(s.index.IsEpisodeInIndex(episode), Equals, false)
+
+	added, err = s.index.AddLanguage("Community", "en", 1, 1)
+	c.Assert(err, ErrorMatches, "series is already watched in this language")
+	c.Assert(added, Equals, false)
+}
+
+func (s *MySuite) TestRemoveLanguage(c *C) {
+	removed, err := s.index.RemoveLanguage("Shameless US", "en")
+	c.Assert(err, IsNil)
+	c.Assert(removed, Equals, true)
+	c.Assert(s.index.SeriesLanguages("Shameless US"), DeepEquals, []string{"de"})
+
+	removed, err = s.index.RemoveLanguage("Shameless US", "en")
+	c.Assert(err, ErrorMatches, "series is not watched in this language")
+	c.Assert(removed, Equals, false)
+
+	removed, err = s.index.RemoveLanguage("Shameless US", "de")
+	c.Assert(err, NotNil)
+	c.Assert(removed, Equals, false)
+}
+
+func (s *MySuite) TestLastEpisodeOfEpisodeSet(c *C) {
+	series := s.index.seriesMap["Shameless US"]
+
+	season, episode, found := series.languageMap["de"].LastEpisode()
+	c.Assert(found, Equals, true)
+	c.Assert(season, Equals, 1)
+	c.Assert(episode, Equals, 8)
+
+	empty := EpisodeSet{}
+	_, _, found = empty.LastEpisode()
+	c.Assert(found, Equals, false)
+}
+
+func (s *MySuite) TestSeriesStatus(c *C) {
+	c.Assert(s.index.SeriesStatus("Community"), Equals, StatusActive)
+
+	err := s.index.SetSeriesStatus("Community", StatusPaused)
+	c.Assert(err, IsNil)
+	c.Assert(s.index.SeriesStatus("Community"), Equals, StatusPaused)
+
+	err = s.index.SetSeriesStatus("Community", "watching")
+	c.Assert(err, ErrorMatches, "invalid status.*")
+
+	err = s.index.SetSeriesStatus("Community", StatusActive)
+	c.Assert(err, IsNil)
+	c.Assert(s.index.seriesMap["Community"].Status, Equals, "")
+}
+
+func (s *MySuite) TestAddEpisodeToDroppedSeries(c *C) {
+	c.Assert(s.index.SetSeriesStatus("Shameless US", StatusDropped), IsNil)
+
+	episode := renamer.Episode{Series: "Shameless US", Season: 1, Episode: 9,
+		Name: "Testepisode", Extension: ".mkv", Language: "de"}
+
+	result, err := s.index.AddEpisode(&episode)
+	c.Assert(err, ErrorMatches, "series has been dropped")
+	c.Assert(result.Added, Equals, false)
+}
@@ -0,0 +1,375 @@
+package index
+
+import (
+	"github.com/pboehm/series/renamer"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var cleanedFileNamePattern = regexp.MustCompile("^S\\d+E\\d+ - .+\\.\\w+$")
+
+// LibraryFile is a video file found in a library that is organized like
+// `Series/Season/S01E01 - Name.ext`
+type LibraryFile struct {
+	Path, SeriesDirectory, FileName string
+}
+
+// UnplacedFile is a file of the library that could not be assigned to an
+// episode in the index
+type UnplacedFile struct {
+	Path, Reason string
+}
+
+// ScannedEpisode is an episode that has been found in the library
+type ScannedEpisode struct {
+	Series, Language, Name string
+	Path                   string
+}
+
+type ScanReport struct {
+	CreatedSeries []string
+	Added         []ScannedEpisode
+	Existing      int
+	Unplaced      []UnplacedFile
+}
+
+// FindLibraryFiles walks the library and returns all video files which are
+// located inside a series directory. Video files directly in the library root
+// are returned as unplaced.
+func FindLibraryFiles(root string) ([]LibraryFile, []UnplacedFile, error) {
+	var files []LibraryFile
+	var unplaced []UnplacedFile
+
+	walker := func(entryPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || !renamer.HasVideoFileEnding(entryPath) {
+			return nil
+		}
+
+		relative, err := filepath.Rel(root, entryPath)
+		if err != nil {
+			return err
+		}
+
+		parts := strings.Split(relative, string(filepath.Separator))
+		if len(parts) < 2 {
+			unplaced = append(unplaced, UnplacedFile{entryPath, "file is not inside a series directory"})
+			return nil
+		}
+
+		files = append(files, LibraryFile{
+			Path:            entryPath,
+			SeriesDirectory: parts[0],
+			FileName:        info.Name(),
+		})
+
+		return nil
+	}
+
+	if err := filepath.Walk(root, walker); err != nil {
+		return nil, nil, err
+	}
+
+	return files, unplaced, nil
+}
+
+// ScanLibrary adds all episodes found in the library to the index. Series
+// which can't be found in the index with the help of the registered
+// SeriesNameExtractors are created under the name of their directory.
+// Episodes without an explicit language are added in the only language the
+// series is watched in or in the supplied language.
+func (s *SeriesIndex) ScanLibrary(root string, language string) (*ScanReport, error) {
+	files, unplaced, err := FindLibraryFiles(root)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ScanReport{Unplaced: unplaced}
+
+	for _, file := range files {
+		episode, reason := s.libraryEpisode(file)
+		if episode == nil {
+			report.Unplaced = append(report.Unplaced, UnplacedFile{file.Path, reason})
+			continue
+		}
+
+		series, existing := s.seriesMap[episode.Series]
+		if !existing {
+			series = s.addEmptySeries(episode.Series)
+			report.CreatedSeries = append(report.CreatedSeries, series.Name)
+		}
+
+		if episode.Language == "" && len(series.languageMap) > 0 {
+			s.GuessEpisodeLanguage(episode, series)
+		}
+		if episode.Language == "" {
+			episode.Language = language
+		}
+
+		if s.IsEpisodeInIndex(*episode) {
+			report.Existing += 1
+			continue
+		}
+
+		filename := file.FileName
+		if !cleanedFileNamePattern.MatchString(filename) {
+			filename = episode.CleanedFileName()
+		}
+
+		set, _ := series.ensureEpisodeSet(episode.Language)
+		set.EpisodeList = append(set.EpisodeList, Episode{Name: filename})
+		set.BuildUpEpisodeMap()
+
+		report.Added = append(report.Added, ScannedEpisode{
+			Series:   series.Name,
+			Language: set.GetLanguage(),
+			Name:     filename,
+			Path:     file.Path,
+		})
+	}
+
+	return report, nil
+}
+
+// libraryEpisode builds an episode from the library file and resolves its
+// series name in the index. When the series is not part of the index, the name
+// of the series directory is used.
+func (s *SeriesIndex) libraryEpisode(file LibraryFile) (*renamer.Episode, string) {
+	information := renamer.ExtractEpisodeInformation(file.FileName)
+	if information == nil {
+		return nil, "file name contains no season and episode"
+	}
+
+	episode := &renamer.Episode{
+		Series:      file.SeriesDirectory,
+		Path:        file.Path,
+		EpisodeFile: file.Path,
+		Extension:   filepath.Ext(file.FileName),
+	}
+	episode.Season, _ = strconv.Atoi(information["season"])
+	episode.Episode, _ = strconv.Atoi(information["episode"])
+
+	name := information["episodename"]
+	episode.Name = renamer.CleanEpisodeInformation(name[:len(name)-len(episode.Extension)])
+	episode.ExtractLanguage()
+	episode.RemoveTrashWords()
+	if !episode.HasValidEpisodeName() {
+		episode.SetDefaultEpisodeName()
+	}
+
+	seriesName := s.resolveSeriesName(episode)
+	if seriesName == "" {
+		seriesName = s.SeriesNameInIndex(episode.Series)
+	}
+	if seriesName != "" {
+		episode.Series = seriesName
+	}
+
+	return episode, ""
+}
+
+// LibraryEpisode is an episode that is part of the index, the library or both
+type LibraryEpisode struct {
+	Series, Language    string
+	IndexName, FileName string
+	Path                string
+}
+
+type VerifyReport struct {
+	MissingOnDisk []LibraryEpisode
+	NotInIndex    []LibraryEpisode
+	Mismatches    []LibraryEpisode
+	Unplaced      []UnplacedFile
+}
+
+func (r *VerifyReport) Clean() bool {
+	return len(r.MissingOnDisk) == 0 && len(r.NotInIndex) == 0 && len(r.Mismatches) == 0
+}
+
+// VerifyLibrary cross-checks the episodes of the index against the files in
+// the library. Files are matched by series, language, season and episode, so
+// that in a series watched in several languages each file only counts for one
+// of them. Files without a language in their name belong to the first language
+// listing the episode which has no file yet.
+func (s *SeriesIndex) VerifyLibrary(root string) (*VerifyReport, error) {
+	files, unplaced, err := FindLibraryFiles(root)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{Unplaced: unplaced}
+
+	var onDisk []LibraryEpisode
+	onDiskKeys := map[string]int{}
+
+	for _, file := range files {
+		episode, reason := s.libraryEpisode(file)
+		if episode == nil {
+			report.Unplaced = append(report.Unplaced, UnplacedFile{file.Path, reason})
+			continue
+		}
+
+		filename := file.FileName
+		if !cleanedFileNamePattern.MatchString(filename) {
+			filename = episode.CleanedFileName()
+		}
+
+		entry := LibraryEpisode{Series: episode.Series, FileName: filename, Path: file.Path}
+
+		series, existing := s.seriesMap[episode.Series]
+		if !existing {
+			report.NotInIndex = append(report.NotInIndex, entry)
+			continue
+		}
+
+		entry.Series = series.Name
+		if episode.Language == "" {
+			episode.Language = unclaimedLanguage(series, onDiskKeys, episode.Season, episode.Episode)
+		}
+		if episode.Language == "" {
+			s.GuessEpisodeLanguage(episode, series)
+		}
+		entry.Language = episode.Language
+
+		onDiskKeys[libraryKey(series.Name, entry.Language, episode.Season, episode.Episode)] = len(onDisk)
+		onDisk = append(onDisk, entry)
+	}
+
+	seen := map[int]bool{}
+
+	for _, series := range s.SeriesList {
+		for _, set := range series.EpisodeSets {
+			for _, episode := range set.EpisodeList {
+				// barriers are kept even when the episode has been deleted
+				if episode.AllBefore {
+					continue
+				}
+
+				matched := renamer.ExtractEpisodeInformation(episode.Name)
+				if matched == nil {
+					continue
+				}
+				nrSeason, _ := strconv.Atoi(matched["season"])
+				nrEpisode, _ := strconv.Atoi(matched["episode"])
+
+				entry := LibraryEpisode{Series: series.Name, Language: set.GetLanguage(), IndexName: episode.Name}
+
+				i, found := onDiskKeys[libraryKey(series.Name, set.GetLanguage(), nrSeason, nrEpisode)]
+				if !found {
+					report.MissingOnDisk = append(report.MissingOnDisk, entry)
+					continue
+				}
+
+				seen[i] = true
+				entry.FileName, entry.Path = onDisk[i].FileName, onDisk[i].Path
+				if entry.FileName != entry.IndexName {
+					report.Mismatches = append(report.Mismatches, entry)
+				}
+			}
+		}
+	}
+
+	for i, entry := range onDisk {
+		if seen[i] {
+			continue
+		}
+
+		// episodes before an all_before barrier are not listed explicitly
+		matched := renamer.ExtractEpisodeInformation(entry.FileName)
+		nrSeason, _ := strconv.Atoi(matched["season"])
+		nrEpisode, _ := strconv.Atoi(matched["episode"])
+
+		if !s.IsEpisodeInIndexManual(entry.Series, entry.Language, nrSeason, nrEpisode) {
+			report.NotInIndex = append(report.NotInIndex, entry)
+		}
+	}
+
+	return report, nil
+}
+
+func libraryKey(series, language string, season, episode int) string {
+	return series + "/" + language + "/" + buildIndexKey(season, episode)
+}
+
+// unclaimedLanguage returns the first language of the series listing the
+// episode for which no file has been found yet
+func unclaimedLanguage(series *Series, onDiskKeys map[string]int, season, episode int) string {
+	for _, set := range series.EpisodeSets {
+		language := set.GetLanguage()
+		if _, listed := set.episodeMap[buildIndexKey(season, episode)]; !listed {
+			continue
+		}
+		if _, claimed := onDiskKeys[libraryKey(series.Name, language, season, episode)]; !claimed {
+			return language
+		}
+	}
+	return ""
+}
+
+// FixFromLibrary changes the index so that it matches the library:
+// mismatching names are taken from the files and files of known series are
+// added. Episodes missing on disk are usually watched and deleted afterwards,
+// so they are only removed when removeMissing is set. It returns the episodes
+// that could not be fixed.
+func (s *SeriesIndex) FixFromLibrary(report *VerifyReport, removeMissing bool) []LibraryEpisode {
+	var unfixable []LibraryEpisode
+
+	if removeMissing {
+		for _, entry := range report.MissingOnDisk {
+			s.replaceEpisodeEntry(entry.Series, entry.Language, entry.IndexName, "")
+		}
+	}
+
+	for _, entry := range report.Mismatches {
+		s.replaceEpisodeEntry(entry.Series, entry.Language, entry.IndexName, entry.FileName)
+	}
+
+	for _, entry := range report.NotInIndex {
+		series, existing := s.seriesMap[entry.Series]
+		if !existing {
+			unfixable = append(unfixable, entry)
+			continue
+		}
+
+		set, _ := series.ensureEpisodeSet(entry.Language)
+		set.EpisodeList = append(set.EpisodeList, Episode{Name: entry.FileName})
+		set.BuildUpEpisodeMap()
+	}
+
+	return unfixable
+}
+
+// replaceEpisodeEntry renames the episode entry or removes it when newName is
+// empty
+func (s *SeriesIndex) replaceEpisodeEntry(seriesname, language, name, newName string) {
+	series, existing := s.seriesMap[seriesname]
+	if !existing {
+		return
+	}
+
+	set, languageExist := series.languageMap[language]
+	if !languageExist {
+		return
+	}
+
+	for i := 0; i < len(set.EpisodeList); i++ {
+		if set.EpisodeList[i].Name != name {
+			continue
+		}
+
+		if newName == "" {
+			set.EpisodeList = append(set.EpisodeList[:i], set.EpisodeList[i+1:]...)
+		} else {
+			set.EpisodeList[i].Name = newName
+		}
+		break
+	}
+
+	set.BuildUpEpisodeMap()
+}
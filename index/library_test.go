@@ -0,0 +1,138 @@
+package index
+
+import (
+	. "launchpad.net/gocheck"
+	"os"
+	"path"
+	"sort"
+)
+
+func createLibrary(root string, files []string) {
+	for _, file := range files {
+		filePath := path.Join(root, file)
+		os.MkdirAll(path.Dir(filePath), 0700)
+		createFile(filePath, "")
+	}
+}
+
+func (s *MySuite) TestFindLibraryFiles(c *C) {
+	createLibrary(s.dir, []string{
+		"Community/Season 1/S01E21 - Kontaktsperre.avi",
+		"Community/Season 1/S01E21 - Kontaktsperre.nfo",
+		"S01E01 - Somewhere.avi",
+	})
+
+	files, unplaced, err := FindLibraryFiles(s.dir)
+	c.Assert(err, IsNil)
+	c.Assert(files, DeepEquals, []LibraryFile{{
+		Path:            path.Join(s.dir, "Community/Season 1/S01E21 - Kontaktsperre.avi"),
+		SeriesDirectory: "Community",
+		FileName:        "S01E21 - Kontaktsperre.avi",
+	}})
+	c.Assert(unplaced, HasLen, 1)
+	c.Assert(unplaced[0].Path, Equals, path.Join(s.dir, "S01E01 - Somewhere.avi"))
+}
+
+func (s *MySuite) TestScanLibrary(c *C) {
+	createLibrary(s.dir, []string{
+		"Community/Season 1/S01E01 - Zurück aufs College.avi",
+		"Community/Season 1/S01E21 - Kontaktsperre.avi",
+		"Community/Season 2/Community.S02E01.Anthropologie.German.mkv",
+		"Dr. House/Staffel 1/S01E01 - Schmerzensgrenzen.mkv",
+		"Dr. House/Staffel 1/Extras.mkv",
+	})
+
+	report, err := s.index.ScanLibrary(s.dir, "en")
+	c.Assert(err, IsNil)
+
+	c.Assert(report.CreatedSeries, DeepEquals, []string{"Dr. House"})
+	c.Assert(report.Existing, Equals, 1)
+	c.Assert(report.Unplaced, HasLen, 1)
+	c.Assert(report.Unplaced[0].Path, Equals, path.Join(s.dir, "Dr. House/Staffel 1/Extras.mkv"))
+
+	var added []string
+	for _, episode := range report.Added {
+		added = append(added, episode.Series+" ["+episode.Language+"] "+episode.Name)
+	}
+	sort.Strings(added)
+	c.Assert(added, DeepEquals, []string{
+		"Community [de] S01E21 - Kontaktsperre.avi",
+		"Community [de] S02E01 - Anthropologie.mkv",
+		"Dr. House [en] S01E01 - Schmerzensgrenzen.mkv",
+	})
+
+	c.Assert(s.index.IsEpisodeInIndexManual("Community", "de", 1, 21), Equals, true)
+	c.Assert(s.index.IsEpisodeInIndexManual("Dr. House", "en", 1, 1), Equals, true)
+}
+
+func (s *MySuite) TestVerifyLibrary(c *C) {
+	createLibrary(s.dir, []string{
+		"The Big Bang Theory/Season 5/S05E10 - Old.avi",
+		"The Big Bang Theory/Season 6/S06E02 - Grundkurs Spanisch.avi",
+		"The Big Bang Theory/Season 6/S06E03 - Carpe Diem (Director's Cut).avi",
+		"The Big Bang Theory/Season 6/S06E05 - Neu.avi",
+		"Unknown/Season 1/S01E01 - Pilot.avi",
+	})
+
+	index := &SeriesIndex{SeriesList: []Series{*s.index.seriesMap["The Big Bang Theory"]}}
+	index.BuildUpSeriesMap()
+
+	report, err := index.VerifyLibrary(s.dir)
+	c.Assert(err, IsNil)
+	c.Assert(report.Clean(), Equals, false)
+
+	c.Assert(report.MissingOnDisk, DeepEquals, []LibraryEpisode{
+		{Series: "The Big Bang Theory", Language: "de", IndexName: "S06E04 - Bitte warten.avi"},
+	})
+	c.Assert(report.Mismatches, HasLen, 1)
+	c.Assert(report.Mismatches[0].IndexName, Equals, "S06E03 - Carpe Diem.avi")
+	c.Assert(report.Mismatches[0].FileName, Equals, "S06E03 - Carpe Diem (Director's Cut).avi")
+
+	c.Assert(report.NotInIndex, HasLen, 2)
+	c.Assert(report.NotInIndex[0].Series, Equals, "Unknown")
+	c.Assert(report.NotInIndex[1].FileName, Equals, "S06E05 - Neu.avi")
+
+	unfixable := index.FixFromLibrary(report, false)
+	c.Assert(unfixable, HasLen, 1)
+	c.Assert(unfixable[0].Series, Equals, "Unknown")
+
+	// watched episodes which have been deleted are kept
+	report, err = index.VerifyLibrary(s.dir)
+	c.Assert(err, IsNil)
+	c.Assert(report.MissingOnDisk, HasLen, 1)
+	c.Assert(report.Mismatches, HasLen, 0)
+	c.Assert(report.NotInIndex, HasLen, 1)
+	c.Assert(index.IsEpisodeInIndexManual("The Big Bang Theory", "de", 6, 4), Equals, true)
+	c.Assert(index.IsEpisodeInIndexManual("The Big Bang Theory", "de", 6, 5), Equals, true)
+
+	index.FixFromLibrary(report, true)
+	report, err = index.VerifyLibrary(s.dir)
+	c.Assert(err, IsNil)
+	c.Assert(report.MissingOnDisk, HasLen, 0)
+	c.Assert(index.IsEpisodeInIndexManual("The Big Bang Theory", "de", 6, 4), Equals, false)
+}
+
+func (s *MySuite) TestVerifyLibraryWithSeveralLanguages(c *C) {
+	createLibrary(s.dir, []string{
+		"Dr. House/Season 1/S01E01 - Pilot.avi",
+		"Dr. House/Season 1/S01E02 - Paternity.avi",
+	})
+
+	index := &SeriesIndex{SeriesList: []Series{{
+		Name: "Dr. House",
+		EpisodeSets: []EpisodeSet{
+			{Language: "de", EpisodeList: []Episode{{Name: "S01E01 - Pilot.avi"}}},
+			{Language: "en", EpisodeList: []Episode{{Name: "S01E01 - Pilot.avi"}, {Name: "S01E02 - Paternity.avi"}}},
+		},
+	}}}
+	index.BuildUpSeriesMap()
+
+	// a single file does not satisfy both languages
+	report, err := index.VerifyLibrary(s.dir)
+	c.Assert(err, IsNil)
+	c.Assert(report.NotInIndex, HasLen, 0)
+	c.Assert(report.Mismatches, HasLen, 0)
+	c.Assert(report.MissingOnDisk, DeepEquals, []LibraryEpisode{
+		{Series: "Dr. House", Language: "en", IndexName: "S01E01 - Pilot.avi"},
+	})
+}
@@ -0,0 +1,141 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pboehm/series/renamer"
+	"strings"
+)
+
+// ErrUnknownSeries is returned when an episode belongs to a series which is
+// not part of the index
+var ErrUnknownSeries = errors.New("series does not exist in index")
+
+// ExtractorCandidates are the series names proposed by one extractor
+type ExtractorCandidates struct {
+	Extractor string
+	Names     []string
+	Err       error
+
+	// Language is the language override of a LanguageExtractor
+	Language string
+}
+
+// MatchResult describes how an episode has been matched against the index
+type MatchResult struct {
+	// ParsedSeries is the series name parsed from the release name
+	ParsedSeries string
+	Candidates   []ExtractorCandidates
+
+	// Series is the name of the matched series in the index, MatchedName the
+	// candidate that led to it and Extractor the extractor proposing it
+	Series      string
+	MatchedName string
+	Extractor   string
+
+	Language       string
+	LanguageReason string
+
+	AlreadyInIndex bool
+	Added          bool
+}
+
+// MatchEpisode runs the whole matching of AddEpisode without changing the
+// index. All extractors are asked, even when an earlier one already matched.
+// Like AddEpisode, it sets the series name and language of the episode.
+func (s *SeriesIndex) MatchEpisode(episode *renamer.Episode) (*MatchResult, error) {
+	result, _, err := s.matchEpisode(episode, true)
+	if err != nil {
+		return result, err
+	}
+
+	result.AlreadyInIndex = s.IsEpisodeInIndex(*episode)
+	return result, nil
+}
+
+func (s *SeriesIndex) matchEpisode(episode *renamer.Episode, exhaustive bool) (*MatchResult, *Series, error) {
+	result := &MatchResult{ParsedSeries: episode.Series}
+
+	if seriesName := s.matchSeriesName(episode, result, exhaustive); seriesName != "" {
+		episode.Series = seriesName
+	}
+
+	series, existing := s.seriesMap[episode.Series]
+	if !existing {
+		return result, nil, ErrUnknownSeries
+	}
+	result.Series = series.Name
+
+	// Handle episodes where no language is set
+	if episode.Language == "" {
+		result.LanguageReason = s.guessEpisodeLanguage(episode, series)
+	} else {
+		result.LanguageReason = "language has been detected in the release name or by an extractor"
+	}
+	result.Language = episode.Language
+
+	return result, series, nil
+}
+
+// matchSeriesName records the candidates of each extractor in the result and
+// returns the first candidate existing in the index. Unless exhaustive is set,
+// the remaining extractors are skipped after a match.
+func (s *SeriesIndex) matchSeriesName(episode *renamer.Episode, result *MatchResult, exhaustive bool) string {
+	matched := ""
+
+	for _, extractor := range s.nameExtractors {
+		var names []string
+		var language string
+		var err error
+		if languageExtractor, ok := extractor.(LanguageExtractor); ok {
+			names, language, err = languageExtractor.NamesAndLanguage(episode)
+		} else {
+			names, err = extractor.Names(episode)
+		}
+
+		result.Candidates = append(result.Candidates, ExtractorCandidates{
+			Extractor: describeExtractor(extractor),
+			Names:     names,
+			Err:       err,
+			Language:  language,
+		})
+
+		if err != nil || matched != "" {
+			continue
+		}
+
+		for _, possibleSeries := range names {
+			seriesName := s.SeriesNameInIndex(possibleSeries)
+			if seriesName != "" {
+				matched = seriesName
+				result.MatchedName = possibleSeries
+				result.Extractor = describeExtractor(extractor)
+				if language != "" {
+					episode.Language = language
+				}
+				break
+			}
+		}
+
+		if matched != "" && !exhaustive {
+			break
+		}
+	}
+
+	return matched
+}
+
+func describeExtractor(extractor SeriesNameExtractor) string {
+	switch e := extractor.(type) {
+	case FilesystemExtractor:
+		return "filesystem"
+	case RegexExtractor:
+		return "regex"
+	case MappingFileExtractor:
+		return "mapping"
+	case ScriptExtractor:
+		return fmt.Sprintf("script %s", e.ScriptPath)
+	default:
+		return strings.TrimPrefix(fmt.Sprintf("%T", extractor), "index.")
+	}
+}
@@ -0,0 +1,113 @@
+package index
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IndexDiff holds the records that have to be added to or removed from one
+// index to get the other one
+type IndexDiff struct {
+	Added, Removed []Record
+}
+
+func (d *IndexDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+func (r Record) String() string {
+	switch r.Kind {
+	case RecordSeries:
+		if r.Status != "" {
+			return fmt.Sprintf("series %s (%s)", r.Series, r.Status)
+		}
+		return fmt.Sprintf("series %s", r.Series)
+	case RecordAlias:
+		return fmt.Sprintf("alias %s -> %s", r.Alias, r.Series)
+	case RecordLanguage:
+		return fmt.Sprintf("language %s [%s]", r.Series, r.Language)
+	case RecordRange:
+		return fmt.Sprintf("range %s [%s] %s", r.Series, r.Language, Range{r.From, r.To})
+	case RecordEpisode:
+		description := fmt.Sprintf("episode %s [%s] %s", r.Series, r.Language, r.Episode)
+		if r.Title != "" {
+			description += fmt.Sprintf(" '%s'", r.Title)
+		}
+		if r.AllBefore {
+			description += " (all before)"
+		}
+		return description
+	default:
+		return fmt.Sprintf("%s %s", r.Kind, r.Series)
+	}
+}
+
+// DiffIndexes compares both indexes based on their exported records. Added
+// contains everything that only exists in b, Removed everything that only
+// exists in a.
+func DiffIndexes(a, b *SeriesIndex) *IndexDiff {
+	aRecords, bRecords := a.ExportRecords(), b.ExportRecords()
+
+	aKeys := map[Record]bool{}
+	for _, record := range aRecords {
+		aKeys[record] = true
+	}
+
+	bKeys := map[Record]bool{}
+	for _, record := range bRecords {
+		bKeys[record] = true
+	}
+
+	diff := &IndexDiff{}
+	for _, record := range bRecords {
+		if !aKeys[record] {
+			diff.Added = append(diff.Added, record)
+		}
+	}
+	for _, record := range aRecords {
+		if !bKeys[record] {
+			diff.Removed = append(diff.Removed, record)
+		}
+	}
+
+	return diff
+}
+
+// Merge unions the other index into this one. Episodes and watched ranges of
+// both indexes are kept per series and language, all_before barriers are
+// reconciled so that the later one wins and contradicting data is reported as
+// conflict.
+func (s *SeriesIndex) Merge(other *SeriesIndex) *ImportReport {
+	return s.ImportRecords(other.ExportRecords())
+}
+
+// reconcileBarrier handles an incoming all_before episode for a set which
+// already has a barrier. As both barriers mark everything before them as
+// watched, only the later one has to be kept. It returns true when the
+// incoming barrier should be added to the set.
+func (e *EpisodeSet) reconcileBarrier(season, episode int) bool {
+	if !e.allBefore {
+		return true
+	}
+
+	if !e.barrier.Before(EpisodeNumber{season, episode}) {
+		return false
+	}
+
+	// drop the old barrier, synthetic entries are removed completely while
+	// real episodes are kept as watched episodes
+	var kept []Episode
+	for _, entry := range e.EpisodeList {
+		if entry.AllBefore {
+			if strings.HasSuffix(entry.Name, preFirstSuffix) {
+				continue
+			}
+			entry.AllBefore = false
+		}
+		kept = append(kept, entry)
+	}
+	e.EpisodeList = kept
+	e.BuildUpEpisodeMap()
+
+	return true
+}
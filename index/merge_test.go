@@ -0,0 +1,97 @@
+package index
+
+import (
+	. "launchpad.net/gocheck"
+)
+
+func (s *MySuite) TestDiffOfEqualIndexes(c *C) {
+	other, err := ParseSeriesIndex("data/seriesindex_example.xml")
+	c.Assert(err, IsNil)
+
+	diff := DiffIndexes(s.index, other)
+	c.Assert(diff.Empty(), Equals, true)
+}
+
+func (s *MySuite) TestDiffIndexes(c *C) {
+	other, err := ParseSeriesIndex("data/seriesindex_example.xml")
+	c.Assert(err, IsNil)
+
+	c.Assert(other.RemoveAlias("Community", "Comm"), IsNil)
+	_, err = other.AddEpisodeManually("Shameless US", "de", 1, 9, "S01E09 - New.avi")
+	c.Assert(err, IsNil)
+
+	diff := DiffIndexes(s.index, other)
+	c.Assert(diff.Added, DeepEquals, []Record{
+		{Kind: RecordEpisode, Series: "Shameless US", Language: "de", Episode: "S01E09 - New.avi"},
+	})
+	c.Assert(diff.Removed, DeepEquals, []Record{
+		{Kind: RecordAlias, Series: "Community", Alias: "Comm"},
+	})
+	c.Assert(diff.Added[0].String(), Equals, "episode Shameless US [de] S01E09 - New.avi")
+}
+
+func (s *MySuite) TestMergeUnionsEpisodes(c *C) {
+	other := &SeriesIndex{}
+	_, err := other.AddSeries("Shameless US", "de", 1, 8)
+	c.Assert(err, IsNil)
+	_, err = other.AddEpisodeManually("Shameless US", "de", 1, 10, "S01E10 - Other.avi")
+	c.Assert(err, IsNil)
+
+	report := s.index.Merge(other)
+	c.Assert(report.Conflicts, HasLen, 0)
+	c.Assert(report.Ranges, Equals, 1)
+	c.Assert(report.Episodes, Equals, 1)
+	c.Assert(s.index.IsEpisodeInIndexManual("Shameless US", "de", 1, 8), Equals, true)
+	c.Assert(s.index.IsEpisodeInIndexManual("Shameless US", "de", 1, 9), Equals, false)
+	c.Assert(s.index.IsEpisodeInIndexManual("Shameless US", "de", 1, 10), Equals, true)
+
+	// merging it again does not add the covered range twice
+	report = s.index.Merge(other)
+	c.Assert(report.Ranges, Equals, 0)
+	c.Assert(report.Episodes, Equals, 0)
+}
+
+func (s *MySuite) TestMergeReconcilesAllBeforeBarriers(c *C) {
+	other := &SeriesIndex{SeriesList: []Series{{
+		Name: "The Big Bang Theory",
+		EpisodeSets: []EpisodeSet{{EpisodeList: []Episode{
+			{Name: "S06E10 - Pre-First.mov", AllBefore: true},
+		}}},
+	}}}
+	other.BuildUpSeriesMap()
+
+	report := s.index.Merge(other)
+	c.Assert(report.Conflicts, HasLen, 0)
+
+	set := s.index.seriesMap["The Big Bang Theory"].languageMap["de"]
+	c.Assert(set.barrier, Equals, EpisodeNumber{6, 10})
+	c.Assert(s.index.IsEpisodeInIndexManual("The Big Bang Theory", "de", 6, 9), Equals, true)
+
+	barriers := 0
+	for _, episode := range set.EpisodeList {
+		if episode.AllBefore {
+			barriers += 1
+		}
+	}
+	c.Assert(barriers, Equals, 1)
+
+	// merging an earlier barrier does not change anything
+	other.SeriesList[0].EpisodeSets[0].EpisodeList[0].Name = "S02E01 - Pre-First.mov"
+	other.BuildUpSeriesMap()
+
+	report = s.index.Merge(other)
+	c.Assert(report.Episodes, Equals, 0)
+	c.Assert(set.barrier, Equals, EpisodeNumber{6, 10})
+}
+
+func (s *MySuite) TestMergeReportsConflictingAliases(c *C) {
+	other := &SeriesIndex{}
+	_, err := other.AddSeries("Shameless US", "de", 1, 1)
+	c.Assert(err, IsNil)
+	c.Assert(other.AliasSeries("Shameless US", "Comm"), IsNil)
+
+	report := s.index.Merge(other)
+	c.Assert(report.Conflicts, HasLen, 1)
+	c.Assert(report.Conflicts[0].String(), Equals,
+		"Shameless US: alias 'Comm': already points to 'Community'")
+}
@@ -0,0 +1,152 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pboehm/series/util"
+	"io/ioutil"
+	"strings"
+)
+
+// CurrentVersion is the version of the index format written by this binary.
+// Indexes without a version attribute have version 1.
+const CurrentVersion = 3
+
+// migrations upgrade an index by one version each, migrations[0] upgrades
+// from version 1 to version 2 and so on
+var migrations = []func(*SeriesIndex) error{
+	migrateAllBeforeToRanges,
+	migrateEpisodeTitles,
+}
+
+func (s *SeriesIndex) GetVersion() int {
+	if s.Version != 0 {
+		return s.Version
+	}
+
+	return 1
+}
+
+func (s *SeriesIndex) NeedsMigration() bool {
+	return s.GetVersion() < CurrentVersion
+}
+
+func checkVersion(s *SeriesIndex) error {
+	if s.GetVersion() > CurrentVersion {
+		return errors.New(fmt.Sprintf(
+			"the index has version %d but this binary only understands up to version %d, "+
+				"please update series", s.GetVersion(), CurrentVersion))
+	}
+
+	return nil
+}
+
+// Migrate upgrades the index step by step to CurrentVersion
+func (s *SeriesIndex) Migrate() error {
+	if err := checkVersion(s); err != nil {
+		return err
+	}
+
+	for version := s.GetVersion(); version < CurrentVersion; version++ {
+		if err := migrations[version-1](s); err != nil {
+			return errors.New(fmt.Sprintf("migrating index from version %d to %d failed: %s",
+				version, version+1, err))
+		}
+
+		s.Version = version + 1
+		s.BuildUpSeriesMap()
+	}
+
+	return nil
+}
+
+// ParseMigratedSeriesIndex parses the index file and upgrades it in memory to
+// CurrentVersion, the file is left unchanged
+func ParseMigratedSeriesIndex(xmlPath string) (*SeriesIndex, error) {
+	index, err := ParseSeriesIndex(xmlPath)
+	if err != nil {
+		return index, err
+	}
+
+	return index, index.Migrate()
+}
+
+// MigrateIndexFile upgrades the index file to CurrentVersion. Before the file
+// is changed, a copy is stored next to it as `<file>.v<version>.bak`. It
+// returns the version the file had before.
+func MigrateIndexFile(xmlPath string) (int, error) {
+	index, err := ParseSeriesIndex(xmlPath)
+	if err != nil {
+		return 0, err
+	}
+
+	version := index.GetVersion()
+	if !index.NeedsMigration() {
+		return version, nil
+	}
+
+	if _, err = BackupVersion(xmlPath, version); err != nil {
+		return version, err
+	}
+
+	if err = index.Migrate(); err != nil {
+		return version, err
+	}
+
+	return version, index.WriteToFile(xmlPath)
+}
+
+// BackupVersion stores a copy of the index file, which has the given version,
+// as `<file>.v<version>.bak` unless such a copy already exists. It returns the
+// path of the copy.
+func BackupVersion(xmlPath string, version int) (string, error) {
+	backup := fmt.Sprintf("%s.v%d.bak", xmlPath, version)
+	if util.PathExists(backup) {
+		return backup, nil
+	}
+
+	content, err := ioutil.ReadFile(xmlPath)
+	if err != nil {
+		return backup, err
+	}
+
+	return backup, ioutil.WriteFile(backup, content, 0644)
+}
+
+// migrateAllBeforeToRanges replaces the synthetic `Pre-First.mov` episodes,
+// which have been added as all_before barrier for new series, by a range
+// covering the same episodes
+func migrateAllBeforeToRanges(s *SeriesIndex) error {
+	for i := range s.SeriesList {
+		series := &s.SeriesList[i]
+
+		for j := range series.EpisodeSets {
+			set := &series.EpisodeSets[j]
+
+			var kept []Episode
+			for _, episode := range set.EpisodeList {
+				if !episode.AllBefore || !strings.HasSuffix(episode.Name, preFirstSuffix) {
+					kept = append(kept, episode)
+					continue
+				}
+
+				number, err := ParseEpisodeNumber(strings.TrimSuffix(episode.Name, preFirstSuffix))
+				if err != nil {
+					return err
+				}
+
+				set.Ranges = append(set.Ranges, Range{To: number.String()})
+			}
+			set.EpisodeList = kept
+		}
+	}
+
+	return nil
+}
+
+// migrateEpisodeTitles changes nothing, version 3 adds the optional title
+// attribute of episodes. The version is raised so that older binaries, which
+// would drop the titles when writing the index, refuse to open it.
+func migrateEpisodeTitles(s *SeriesIndex) error {
+	return nil
+}
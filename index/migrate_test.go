@@ -0,0 +1,101 @@
+package index
+
+import (
+	"github.com/pboehm/series/util"
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"path"
+)
+
+func (s *MySuite) TestLegacyIndexVersion(c *C) {
+	c.Assert(s.index.Version, Equals, 0)
+	c.Assert(s.index.GetVersion(), Equals, 1)
+	c.Assert(s.index.NeedsMigration(), Equals, true)
+}
+
+func (s *MySuite) TestMigrateAllBeforeToRanges(c *C) {
+	index := &SeriesIndex{SeriesList: []Series{{
+		Name: "Dr. House",
+		EpisodeSets: []EpisodeSet{{EpisodeList: []Episode{
+			{Name: "S03E04 - Pre-First.mov", AllBefore: true},
+			{Name: "S03E05 - Ein Episode.avi"},
+		}}},
+	}}}
+	index.BuildUpSeriesMap()
+
+	c.Assert(index.Migrate(), IsNil)
+	c.Assert(index.Version, Equals, CurrentVersion)
+
+	set := index.seriesMap["Dr. House"].languageMap["de"]
+	c.Assert(set.Ranges, DeepEquals, []Range{{To: "S03E04"}})
+	c.Assert(set.EpisodeList, DeepEquals, []Episode{{Name: "S03E05 - Ein Episode.avi"}})
+	c.Assert(index.IsEpisodeInIndexManual("Dr. House", "de", 3, 4), Equals, true)
+	c.Assert(index.IsEpisodeInIndexManual("Dr. House", "de", 3, 6), Equals, false)
+}
+
+func (s *MySuite) TestMigrateIndexFile(c *C) {
+	content, err := ioutil.ReadFile("data/seriesindex_example.xml")
+	c.Assert(err, IsNil)
+
+	dest := path.Join(s.dir, "index.xml")
+	c.Assert(ioutil.WriteFile(dest, content, 0644), IsNil)
+
+	version, err := MigrateIndexFile(dest)
+	c.Assert(err, IsNil)
+	c.Assert(version, Equals, 1)
+	c.Assert(util.PathExists(dest+".v1.bak"), Equals, true)
+
+	migrated, err := ParseSeriesIndex(dest)
+	c.Assert(err, IsNil)
+	c.Assert(migrated.Version, Equals, CurrentVersion)
+	c.Assert(migrated.NeedsMigration(), Equals, false)
+
+	// the migrated index still knows all episodes
+	diff := DiffIndexes(s.index, migrated)
+	c.Assert(diff.Empty(), Equals, true)
+
+	version, err = MigrateIndexFile(dest)
+	c.Assert(err, IsNil)
+	c.Assert(version, Equals, CurrentVersion)
+}
+
+func (s *MySuite) TestMergeVersion1File(c *C) {
+	other := path.Join(s.dir, "other.xml")
+	createFile(other, `<?xml version="1.0" encoding="UTF-8"?>
+<seriesindex>
+  <series name="Dr. House">
+    <episodes>
+      <episode name="S03E04 - Pre-First.mov" all_before="true" />
+      <episode name="S03E05 - Ein Episode.avi" />
+    </episodes>
+  </series>
+</seriesindex>`)
+
+	migrated, err := ParseMigratedSeriesIndex(other)
+	c.Assert(err, IsNil)
+	c.Assert(migrated.Version, Equals, CurrentVersion)
+
+	report := s.index.Merge(migrated)
+	c.Assert(report.Conflicts, HasLen, 0)
+	c.Assert(report.Series, Equals, 1)
+	c.Assert(report.Ranges, Equals, 1)
+	c.Assert(report.Episodes, Equals, 1)
+
+	set := s.index.seriesMap["Dr. House"].languageMap["de"]
+	c.Assert(set.Ranges, DeepEquals, []Range{{To: "S03E04"}})
+	c.Assert(set.EpisodeList, DeepEquals, []Episode{{Name: "S03E05 - Ein Episode.avi"}})
+
+	// the file itself is not migrated
+	unchanged, err := ParseSeriesIndex(other)
+	c.Assert(err, IsNil)
+	c.Assert(unchanged.GetVersion(), Equals, 1)
+}
+
+func (s *MySuite) TestParseIndexWithNewerVersion(c *C) {
+	dest := path.Join(s.dir, "index.xml")
+	createFile(dest, `<?xml version="1.0" encoding="UTF-8"?>
+<seriesindex version="99"></seriesindex>`)
+
+	_, err := ParseSeriesIndex(dest)
+	c.Assert(err, ErrorMatches, "the index has version 99 but this binary only understands up to version 3.*")
+}
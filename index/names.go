@@ -1,18 +1,34 @@
 package index
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/pboehm/series/renamer"
 	"github.com/pboehm/series/util"
+	"os"
 	"os/exec"
 	"path"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 )
 
 type SeriesNameExtractor interface {
 	Names(*renamer.Episode) ([]string, error)
 }
 
+// LanguageExtractor is a SeriesNameExtractor which can also override the
+// language of the episode. The language is only applied when one of its names
+// matches a series in the index.
+type LanguageExtractor interface {
+	NamesAndLanguage(*renamer.Episode) ([]string, string, error)
+}
+
 type FilesystemExtractor struct{}
 
 func (f FilesystemExtractor) Names(episode *renamer.Episode) ([]string, error) {
@@ -45,19 +61,232 @@ func (f FilesystemExtractor) Names(episode *renamer.Episode) ([]string, error) {
 	return possibilities, nil
 }
 
+// DefaultScriptTimeout is used for ScriptExtractors without a timeout
+const DefaultScriptTimeout = 10 * time.Second
+
+// ScriptExtractor asks an external script for series names. The script is
+// called without a shell with the episode file and `<season>_<episode>` as
+// arguments and gets a JSON scriptRequest on stdin. It can answer with a JSON
+// scriptResponse or with one series name per line.
 type ScriptExtractor struct {
 	ScriptPath string
+	Timeout    time.Duration
+}
+
+type scriptRequest struct {
+	Path     string `json:"path"`
+	Season   int    `json:"season"`
+	Episode  int    `json:"episode"`
+	Series   string `json:"series"`
+	Language string `json:"language"`
+}
+
+type scriptResponse struct {
+	Names []struct {
+		Name     string `json:"name"`
+		Priority int    `json:"priority"`
+	} `json:"names"`
+	Language string `json:"language"`
 }
 
 func (s ScriptExtractor) Names(episode *renamer.Episode) ([]string, error) {
-	script := fmt.Sprintf("%s \"%s\" %d_%d",
-		s.ScriptPath, episode.EpisodeFile, episode.Season, episode.Episode)
-	cmd := exec.Command("/bin/sh", "-c", script)
+	names, _, err := s.NamesAndLanguage(episode)
+	return names, err
+}
+
+// NamesAndLanguage returns the names and the language override of a JSON
+// scriptResponse, the language is "" when the script did not send one
+func (s ScriptExtractor) NamesAndLanguage(episode *renamer.Episode) ([]string, string, error) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultScriptTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	request, err := json.Marshal(scriptRequest{
+		Path:     episode.EpisodeFile,
+		Season:   episode.Season,
+		Episode:  episode.Episode,
+		Series:   episode.Series,
+		Language: episode.Language,
+	})
+	if err != nil {
+		return []string{}, "", err
+	}
+
+	cmd := exec.CommandContext(ctx, s.ScriptPath,
+		episode.EpisodeFile, fmt.Sprintf("%d_%d", episode.Season, episode.Episode))
+	cmd.Stdin = bytes.NewReader(request)
 
 	output, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return []string{}, "", errors.New(fmt.Sprintf("script '%s' timed out after %s", s.ScriptPath, timeout))
+	}
 	if err != nil {
-		return []string{}, err
+		return []string{}, "", err
+	}
+
+	trimmed := strings.TrimSpace(string(output))
+	if strings.HasPrefix(trimmed, "{") {
+		return parseScriptResponse([]byte(trimmed))
+	}
+
+	names := []string{}
+	for _, line := range strings.Split(trimmed, "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names, "", nil
+}
+
+// parseScriptResponse returns the names ordered by descending priority and the
+// language override
+func parseScriptResponse(output []byte) ([]string, string, error) {
+	var response scriptResponse
+	if err := json.Unmarshal(output, &response); err != nil {
+		return []string{}, "", errors.New(fmt.Sprintf("invalid script response: %s", err))
+	}
+
+	sort.SliceStable(response.Names, func(i, j int) bool {
+		return response.Names[i].Priority > response.Names[j].Priority
+	})
+
+	names := []string{}
+	for _, entry := range response.Names {
+		if name := strings.TrimSpace(entry.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names, response.Language, nil
+}
+
+// RegexRule maps all episodes whose file or release directory name matches
+// Pattern to Series. Series can reference capture groups like `$1` or
+// `${name}`, dots and dashes in the expanded name are replaced by spaces.
+type RegexRule struct {
+	Pattern string
+	Series  string
+}
+
+type compiledRegexRule struct {
+	pattern *regexp.Regexp
+	series  string
+}
+
+type RegexExtractor struct {
+	rules []compiledRegexRule
+}
+
+func NewRegexExtractor(rules []RegexRule) (RegexExtractor, error) {
+	extractor := RegexExtractor{}
+
+	for _, rule := range rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return extractor, errors.New(fmt.Sprintf("invalid pattern '%s': %s", rule.Pattern, err))
+		}
+
+		extractor.rules = append(extractor.rules, compiledRegexRule{pattern, rule.Series})
+	}
+
+	return extractor, nil
+}
+
+func (r RegexExtractor) Names(episode *renamer.Episode) ([]string, error) {
+	var names []string
+
+	for _, rule := range r.rules {
+		for _, releaseName := range releaseNames(episode) {
+			match := rule.pattern.FindStringSubmatchIndex(releaseName)
+			if match == nil {
+				continue
+			}
+
+			name := rule.series
+			if strings.Contains(name, "$") {
+				expanded := rule.pattern.ExpandString(nil, rule.series, releaseName, match)
+				name = renamer.CleanEpisodeInformation(string(expanded))
+			}
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+type mappingEntry struct {
+	prefix, series string
+}
+
+// MappingFileExtractor maps release names to series by their prefix. The
+// mapping file contains one `prefix = Series Name` entry per line, empty lines
+// and lines starting with `#` are ignored. Prefixes are compared case
+// insensitive.
+type MappingFileExtractor struct {
+	entries []mappingEntry
+}
+
+func NewMappingFileExtractor(mappingFile string) (MappingFileExtractor, error) {
+	extractor := MappingFileExtractor{}
+
+	file, err := os.Open(mappingFile)
+	if err != nil {
+		return extractor, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return extractor, errors.New(fmt.Sprintf(
+				"%s:%d: entry has not the format 'prefix = Series Name'", mappingFile, lineNumber))
+		}
+
+		extractor.entries = append(extractor.entries, mappingEntry{
+			prefix: strings.ToLower(strings.TrimSpace(parts[0])),
+			series: strings.TrimSpace(parts[1]),
+		})
+	}
+
+	return extractor, scanner.Err()
+}
+
+func (m MappingFileExtractor) Names(episode *renamer.Episode) ([]string, error) {
+	var names []string
+
+	for _, entry := range m.entries {
+		for _, releaseName := range releaseNames(episode) {
+			if strings.HasPrefix(strings.ToLower(releaseName), entry.prefix) {
+				names = append(names, entry.series)
+			}
+		}
+	}
+
+	return names, nil
+}
+
+// releaseNames returns the name of the release directory, if there is one,
+// and the name of the episode file
+func releaseNames(episode *renamer.Episode) []string {
+	names := []string{}
+
+	if episode.Path != "" && episode.Path != episode.EpisodeFile {
+		names = append(names, path.Base(episode.Path))
+	}
+	if episode.EpisodeFile != "" {
+		names = append(names, path.Base(episode.EpisodeFile))
 	}
 
-	return strings.Split(string(output), "\n"), nil
+	return names
 }
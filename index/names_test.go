@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 )
 
 func TestExtractor(t *testing.T) { TestingT(t) }
@@ -81,3 +82,102 @@ func (s *ExtractorSuite) TestEpisodePossibleSeriesNamesFromFile(c *C) {
 	c.Assert(names, DeepEquals, []string{"Criminal Minds"})
 	c.Assert(err, IsNil)
 }
+
+func (s *ExtractorSuite) TestRegexExtractor(c *C) {
+	extractor, err := NewRegexExtractor([]RegexRule{
+		{Pattern: "^(?i)tvp-egagement", Series: "Rules of Engagement"},
+		{Pattern: "^(?P<series>[\\w.]+)\\.S\\d+E\\d+", Series: "${series}"},
+		{Pattern: "^Unrelated", Series: "Unrelated"},
+	})
+	c.Assert(err, IsNil)
+
+	episode, _ := renamer.CreateEpisodeFromPath(
+		path.Dir(s.FileWithPath("rules_of_engagement")))
+
+	names, err := extractor.Names(episode)
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{"Rules of Engagement"})
+
+	episode, _ = renamer.CreateEpisodeFromPath(s.FileWithPath("crmi"))
+	names, _ = extractor.Names(episode)
+	c.Assert(names, DeepEquals, []string{"Criminal Minds"})
+}
+
+func (s *ExtractorSuite) TestRegexExtractorWithInvalidPattern(c *C) {
+	_, err := NewRegexExtractor([]RegexRule{{Pattern: "(", Series: "Broken"}})
+	c.Assert(err, NotNil)
+}
+
+func (s *ExtractorSuite) TestMappingFileExtractor(c *C) {
+	mappingFile := path.Join(s.dir, "mapping.txt")
+	createFile(mappingFile, "# release groups\n\nTVP-EGAGEMENT = Rules of Engagement\ncriminal = Criminal Minds\n")
+
+	extractor, err := NewMappingFileExtractor(mappingFile)
+	c.Assert(err, IsNil)
+
+	episode, _ := renamer.CreateEpisodeFromPath(
+		path.Dir(s.FileWithPath("rules_of_engagement")))
+	names, err := extractor.Names(episode)
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{"Rules of Engagement"})
+
+	episode, _ = renamer.CreateEpisodeFromPath(s.FileWithPath("crmi"))
+	names, _ = extractor.Names(episode)
+	c.Assert(names, DeepEquals, []string{"Criminal Minds"})
+}
+
+func (s *ExtractorSuite) TestMappingFileExtractorWithInvalidEntry(c *C) {
+	mappingFile := path.Join(s.dir, "mapping.txt")
+	createFile(mappingFile, "tvp = Rules of Engagement\nno separator\n")
+
+	_, err := NewMappingFileExtractor(mappingFile)
+	c.Assert(err, ErrorMatches, ".*mapping.txt:2: .*")
+}
+
+func createScript(path string, content string) {
+	_ = ioutil.WriteFile(path, []byte("#!/bin/sh\n"+content), 0755)
+}
+
+func (s *ExtractorSuite) TestScriptExtractorWithLineOutput(c *C) {
+	script := path.Join(s.dir, "names.sh")
+	createScript(script, "echo \"$1\"\necho\necho \"$2\"\n")
+
+	episode, _ := renamer.CreateEpisodeFromPath(s.FileWithPath("crmi"))
+	episode.EpisodeFile = path.Join(s.dir, "Criminal \"Minds\" $HOME.mkv")
+
+	names, err := ScriptExtractor{ScriptPath: script}.Names(episode)
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{episode.EpisodeFile, "1_1"})
+}
+
+func (s *ExtractorSuite) TestScriptExtractorWithJsonProtocol(c *C) {
+	script := path.Join(s.dir, "names.sh")
+	createScript(script, `
+request=$(cat)
+case "$request" in
+  *'"series":"Criminal Minds"'*) ;;
+  *) exit 1 ;;
+esac
+echo '{"names": [{"name": "Criminal", "priority": 1}, {"name": "Criminal Minds", "priority": 5}], "language": "fr"}'
+`)
+
+	episode, _ := renamer.CreateEpisodeFromPath(s.FileWithPath("crmi"))
+
+	names, language, err := ScriptExtractor{ScriptPath: script}.NamesAndLanguage(episode)
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{"Criminal Minds", "Criminal"})
+	c.Assert(language, Equals, "fr")
+
+	// the language is only applied when a name matches the index
+	c.Assert(episode.Language, Equals, "")
+}
+
+func (s *ExtractorSuite) TestScriptExtractorTimeout(c *C) {
+	script := path.Join(s.dir, "names.sh")
+	createScript(script, "exec sleep 5\n")
+
+	episode, _ := renamer.CreateEpisodeFromPath(s.FileWithPath("crmi"))
+
+	_, err := ScriptExtractor{ScriptPath: script, Timeout: 100 * time.Millisecond}.Names(episode)
+	c.Assert(err, ErrorMatches, ".*timed out after 100ms")
+}
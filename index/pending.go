@@ -0,0 +1,93 @@
+package index
+
+import (
+	"encoding/json"
+	"github.com/pboehm/series/renamer"
+	"github.com/pboehm/series/util"
+	"io/ioutil"
+	"time"
+)
+
+// PendingEpisode is an episode of an unknown series that waits for the user to
+// add the series to the index
+type PendingEpisode struct {
+	Path     string
+	Series   string
+	Season   int
+	Episode  int
+	Language string
+	Queued   time.Time
+}
+
+// PendingList holds the PendingEpisodes and is stored as JSON file
+type PendingList struct {
+	Episodes []PendingEpisode
+}
+
+// LoadPendingList reads the list from the file. A missing file results in an
+// empty list.
+func LoadPendingList(file string) (*PendingList, error) {
+	list := &PendingList{Episodes: []PendingEpisode{}}
+	if !util.PathExists(file) {
+		return list, nil
+	}
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(content, list); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (p *PendingList) Save(file string) error {
+	marshaled, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, marshaled, 0644)
+}
+
+// Add queues the episode found at episodePath unless it is already queued
+func (p *PendingList) Add(episodePath string, episode *renamer.Episode) bool {
+	if p.Contains(episodePath) {
+		return false
+	}
+
+	p.Episodes = append(p.Episodes, PendingEpisode{
+		Path:     episodePath,
+		Series:   episode.Series,
+		Season:   episode.Season,
+		Episode:  episode.Episode,
+		Language: episode.Language,
+		Queued:   time.Now(),
+	})
+
+	return true
+}
+
+func (p *PendingList) Contains(episodePath string) bool {
+	for _, pending := range p.Episodes {
+		if pending.Path == episodePath {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *PendingList) Remove(episodePath string) bool {
+	for i, pending := range p.Episodes {
+		if pending.Path == episodePath {
+			p.Episodes = append(p.Episodes[:i], p.Episodes[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
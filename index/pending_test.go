@@ -0,0 +1,38 @@
+package index
+
+import (
+	"github.com/pboehm/series/renamer"
+	. "launchpad.net/gocheck"
+	"path"
+)
+
+func (s *MySuite) TestPendingList(c *C) {
+	file := path.Join(s.dir, "pending.json")
+
+	pending, err := LoadPendingList(file)
+	c.Assert(err, IsNil)
+	c.Assert(pending.Episodes, HasLen, 0)
+
+	episode := &renamer.Episode{Series: "Unknown Series", Season: 2, Episode: 3, Language: "de"}
+	c.Assert(pending.Add("/downloads/Unknown.Series.S02E03.German.mkv", episode), Equals, true)
+	c.Assert(pending.Add("/downloads/Unknown.Series.S02E03.German.mkv", episode), Equals, false)
+	c.Assert(pending.Save(file), IsNil)
+
+	pending, err = LoadPendingList(file)
+	c.Assert(err, IsNil)
+	c.Assert(pending.Episodes, HasLen, 1)
+	c.Assert(pending.Episodes[0].Series, Equals, "Unknown Series")
+	c.Assert(pending.Episodes[0].Season, Equals, 2)
+	c.Assert(pending.Contains("/downloads/Unknown.Series.S02E03.German.mkv"), Equals, true)
+
+	c.Assert(pending.Remove("/downloads/Unknown.Series.S02E03.German.mkv"), Equals, true)
+	c.Assert(pending.Remove("/downloads/Unknown.Series.S02E03.German.mkv"), Equals, false)
+	c.Assert(pending.Episodes, HasLen, 0)
+}
+
+func (s *MySuite) TestAddEpisodeOfUnknownSeries(c *C) {
+	episode := renamer.Episode{Series: "Unknown Series", Season: 1, Episode: 1}
+
+	_, err := s.index.AddEpisode(&episode)
+	c.Assert(err, Equals, ErrUnknownSeries)
+}
@@ -0,0 +1,163 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pboehm/series/util"
+	"regexp"
+	"strconv"
+)
+
+var episodeNumberPattern = regexp.MustCompile("^(?i)S(?P<season>\\d+)E(?P<episode>\\d+)$")
+
+// EpisodeNumber identifies an episode by its season and episode number.
+// Episodes are ordered by season first, so that seasons with 100 and more
+// episodes are handled correctly.
+type EpisodeNumber struct {
+	Season, Episode int
+}
+
+func ParseEpisodeNumber(str string) (EpisodeNumber, error) {
+	groups, matched := util.NamedCaptureGroups(episodeNumberPattern, str)
+	if !matched {
+		return EpisodeNumber{}, errors.New(
+			fmt.Sprintf("'%s' does not have the correct format like: S01E01", str))
+	}
+
+	season, _ := strconv.Atoi(groups["season"])
+	episode, _ := strconv.Atoi(groups["episode"])
+
+	return EpisodeNumber{season, episode}, nil
+}
+
+func (n EpisodeNumber) Before(other EpisodeNumber) bool {
+	if n.Season != other.Season {
+		return n.Season < other.Season
+	}
+
+	return n.Episode < other.Episode
+}
+
+func (n EpisodeNumber) String() string {
+	return fmt.Sprintf("S%02dE%02d", n.Season, n.Episode)
+}
+
+// Range marks all episodes between From and To (both inclusive) as watched.
+// When From is empty, the range starts with the very first episode.
+type Range struct {
+	From string `xml:"from,attr,omitempty"`
+	To   string `xml:"to,attr"`
+}
+
+func NewRange(from, to EpisodeNumber) (Range, error) {
+	if to.Before(from) {
+		return Range{}, errors.New(fmt.Sprintf("range end %s is before its start %s", to, from))
+	}
+
+	return Range{From: from.String(), To: to.String()}, nil
+}
+
+func (r Range) String() string {
+	from := r.From
+	if from == "" {
+		from = "start"
+	}
+
+	return fmt.Sprintf("%s-%s", from, r.To)
+}
+
+func (r Range) bounds() (from EpisodeNumber, to EpisodeNumber, err error) {
+	if r.From != "" {
+		if from, err = ParseEpisodeNumber(r.From); err != nil {
+			return
+		}
+	}
+
+	to, err = ParseEpisodeNumber(r.To)
+	return
+}
+
+type episodeRange struct {
+	from, to EpisodeNumber
+}
+
+func (r episodeRange) contains(number EpisodeNumber) bool {
+	return !number.Before(r.from) && !r.to.Before(number)
+}
+
+func (r episodeRange) covers(other episodeRange) bool {
+	return r.contains(other.from) && r.contains(other.to)
+}
+
+// AddRange marks all episodes between from and to as watched in the supplied
+// language
+func (s *SeriesIndex) AddRange(seriesname, language string, from, to EpisodeNumber) error {
+	set, err := s.episodeSet(seriesname, language)
+	if err != nil {
+		return err
+	}
+
+	newRange, err := NewRange(from, to)
+	if err != nil {
+		return err
+	}
+
+	if !set.addRange(newRange) {
+		return errors.New("range is already covered by an existing range")
+	}
+
+	return nil
+}
+
+func (s *SeriesIndex) RemoveRange(seriesname, language string, from, to EpisodeNumber) error {
+	set, err := s.episodeSet(seriesname, language)
+	if err != nil {
+		return err
+	}
+
+	for i, existing := range set.Ranges {
+		existingFrom, existingTo, err := existing.bounds()
+		if err == nil && existingFrom == from && existingTo == to {
+			set.Ranges = append(set.Ranges[:i], set.Ranges[i+1:]...)
+			set.BuildUpEpisodeMap()
+			return nil
+		}
+	}
+
+	return errors.New("range does not exist")
+}
+
+func (s *SeriesIndex) episodeSet(seriesname, language string) (*EpisodeSet, error) {
+	series, existing := s.seriesMap[seriesname]
+	if !existing {
+		return nil, errors.New("series does not exist in index")
+	}
+
+	set, languageExist := series.languageMap[language]
+	if !languageExist {
+		return nil, errors.New("series is not watched in this language")
+	}
+
+	return set, nil
+}
+
+// addRange adds the range unless it is already covered completely by another
+// range of this set
+func (e *EpisodeSet) addRange(newRange Range) bool {
+	from, to, err := newRange.bounds()
+	if err != nil {
+		return false
+	}
+
+	added := episodeRange{from, to}
+	for _, existing := range e.ranges {
+		if existing.covers(added) {
+			return false
+		}
+	}
+
+	e.Ranges = append(e.Ranges, newRange)
+	e.BuildUpEpisodeMap()
+
+	return true
+}
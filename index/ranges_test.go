@@ -0,0 +1,93 @@
+package index
+
+import (
+	. "launchpad.net/gocheck"
+)
+
+func (s *MySuite) TestParseEpisodeNumber(c *C) {
+	number, err := ParseEpisodeNumber("S04E122")
+	c.Assert(err, IsNil)
+	c.Assert(number, Equals, EpisodeNumber{4, 122})
+	c.Assert(number.String(), Equals, "S04E122")
+
+	_, err = ParseEpisodeNumber("4x12")
+	c.Assert(err, ErrorMatches, ".*does not have the correct format.*")
+}
+
+func (s *MySuite) TestEpisodeNumberOrdering(c *C) {
+	c.Assert(EpisodeNumber{1, 150}.Before(EpisodeNumber{2, 1}), Equals, true)
+	c.Assert(EpisodeNumber{2, 1}.Before(EpisodeNumber{1, 150}), Equals, false)
+	c.Assert(EpisodeNumber{2, 1}.Before(EpisodeNumber{2, 1}), Equals, false)
+}
+
+func (s *MySuite) TestAllBeforeWithLargeEpisodeNumbers(c *C) {
+	set := EpisodeSet{EpisodeList: []Episode{
+		{Name: "S02E01 - Pre-First.mov", AllBefore: true},
+	}}
+	set.BuildUpEpisodeMap()
+
+	c.Assert(set.covers(EpisodeNumber{1, 150}), Equals, true)
+	c.Assert(set.covers(EpisodeNumber{2, 2}), Equals, false)
+}
+
+func (s *MySuite) TestMultipleAllBeforeBarriers(c *C) {
+	set := EpisodeSet{EpisodeList: []Episode{
+		{Name: "S03E01 - Later.avi", AllBefore: true},
+		{Name: "S01E05 - Earlier.avi", AllBefore: true},
+	}}
+	set.BuildUpEpisodeMap()
+
+	c.Assert(set.barrier, Equals, EpisodeNumber{3, 1})
+	c.Assert(set.covers(EpisodeNumber{2, 10}), Equals, true)
+}
+
+func (s *MySuite) TestAddRange(c *C) {
+	err := s.index.AddRange("Shameless US", "de", EpisodeNumber{2, 1}, EpisodeNumber{2, 12})
+	c.Assert(err, IsNil)
+
+	c.Assert(s.index.IsEpisodeInIndexManual("Shameless US", "de", 2, 1), Equals, true)
+	c.Assert(s.index.IsEpisodeInIndexManual("Shameless US", "de", 2, 12), Equals, true)
+	c.Assert(s.index.IsEpisodeInIndexManual("Shameless US", "de", 2, 13), Equals, false)
+	c.Assert(s.index.IsEpisodeInIndexManual("Shameless US", "de", 1, 9), Equals, false)
+
+	season, episode, found := s.index.seriesMap["Shameless US"].languageMap["de"].LastEpisode()
+	c.Assert(found, Equals, true)
+	c.Assert(EpisodeNumber{season, episode}, Equals, EpisodeNumber{2, 12})
+
+	err = s.index.AddRange("Shameless US", "de", EpisodeNumber{2, 3}, EpisodeNumber{2, 4})
+	c.Assert(err, ErrorMatches, "range is already covered by an existing range")
+
+	err = s.index.AddRange("Shameless US", "de", EpisodeNumber{3, 3}, EpisodeNumber{2, 4})
+	c.Assert(err, ErrorMatches, "range end S02E04 is before its start S03E03")
+
+	err = s.index.RemoveRange("Shameless US", "de", EpisodeNumber{2, 1}, EpisodeNumber{2, 12})
+	c.Assert(err, IsNil)
+	c.Assert(s.index.IsEpisodeInIndexManual("Shameless US", "de", 2, 1), Equals, false)
+}
+
+func (s *MySuite) TestAddSeriesCreatesRange(c *C) {
+	_, err := s.index.AddSeries("Dr. House", "en", 3, 4)
+	c.Assert(err, IsNil)
+
+	set := s.index.seriesMap["Dr. House"].languageMap["en"]
+	c.Assert(set.Ranges, DeepEquals, []Range{{To: "S03E04"}})
+	c.Assert(set.EpisodeList, HasLen, 0)
+
+	c.Assert(s.index.IsEpisodeInIndexManual("Dr. House", "en", 1, 1), Equals, true)
+	c.Assert(s.index.IsEpisodeInIndexManual("Dr. House", "en", 3, 4), Equals, true)
+	c.Assert(s.index.IsEpisodeInIndexManual("Dr. House", "en", 3, 5), Equals, false)
+}
+
+func (s *MySuite) TestAddSeriesStartingAtFirstEpisodeCreatesNoRange(c *C) {
+	_, err := s.index.AddSeries("Dr. House", "en", 1, 0)
+	c.Assert(err, IsNil)
+
+	set := s.index.seriesMap["Dr. House"].languageMap["en"]
+	c.Assert(set.Ranges, HasLen, 0)
+	_, _, found := set.LastEpisode()
+	c.Assert(found, Equals, false)
+
+	_, err = s.index.AddSeries("Lost", "en", 2, 0)
+	c.Assert(err, IsNil)
+	c.Assert(s.index.seriesMap["Lost"].languageMap["en"].Ranges, DeepEquals, []Range{{To: "S02E00"}})
+}
@@ -0,0 +1,126 @@
+package index
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/pboehm/series/renamer"
+	"github.com/pboehm/series/util"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// TitleProvider looks up episode titles in a local source
+type TitleProvider interface {
+	Title(seriesNameInIndex string, language string, season int, episode int) (string, bool)
+}
+
+// MetadataDirectory is a TitleProvider that reads the titles from one JSON file
+// per series, named like the series in the index (e.g. `Community.json`):
+//
+//	{"de": {"S01E01": "Zurück aufs College"}, "en": {"S01E01": "Pilot"}}
+type MetadataDirectory struct {
+	Directory string
+}
+
+func (m MetadataDirectory) Title(seriesNameInIndex string, language string, season int, episode int) (string, bool) {
+	file := path.Join(m.Directory, seriesNameInIndex+".json")
+	if !util.IsFile(file) {
+		return "", false
+	}
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", false
+	}
+
+	var titles map[string]map[string]string
+	if err = json.Unmarshal(content, &titles); err != nil {
+		return "", false
+	}
+
+	title, found := titles[language][EpisodeNumber{season, episode}.String()]
+	return title, found && title != ""
+}
+
+// AddTitleProvider adds another source for episode titles. Providers are asked
+// in order of addition after the titles stored in the index.
+func (s *SeriesIndex) AddTitleProvider(provider TitleProvider) {
+	s.titleProviders = append(s.titleProviders, provider)
+}
+
+// SetEpisodeTitle stores the title of an episode which is already listed in
+// the index
+func (s *SeriesIndex) SetEpisodeTitle(seriesNameInIndex string, language string, season int, episode int, title string) error {
+	set, err := s.episodeSet(seriesNameInIndex, language)
+	if err != nil {
+		return err
+	}
+
+	name, existing := set.episodeMap[buildIndexKey(season, episode)]
+	if !existing {
+		return errors.New("episode is not listed in index")
+	}
+
+	for i := range set.EpisodeList {
+		if set.EpisodeList[i].Name == name {
+			set.EpisodeList[i].Title = title
+		}
+	}
+
+	return nil
+}
+
+// EpisodeTitle returns the title of the episode, either stored in the index or
+// provided by one of the TitleProviders
+func (s *SeriesIndex) EpisodeTitle(seriesNameInIndex string, language string, season int, episode int) (string, bool) {
+	if set, err := s.episodeSet(seriesNameInIndex, language); err == nil {
+		name := set.episodeMap[buildIndexKey(season, episode)]
+		for _, entry := range set.EpisodeList {
+			if entry.Name == name && entry.Title != "" {
+				return entry.Title, true
+			}
+		}
+	}
+
+	for _, provider := range s.titleProviders {
+		if title, found := provider.Title(seriesNameInIndex, language, season, episode); found {
+			return title, true
+		}
+	}
+
+	return "", false
+}
+
+// LookupEpisodeTitle tries to find the title for an episode whose release does
+// not contain one. The series name and language are determined like in
+// AddEpisode but the supplied episode is left unchanged.
+func (s *SeriesIndex) LookupEpisodeTitle(episode *renamer.Episode) (string, bool) {
+	lookup := *episode
+
+	if seriesName := s.resolveSeriesName(&lookup); seriesName != "" {
+		lookup.Series = seriesName
+	} else if seriesName = s.SeriesNameInIndex(lookup.Series); seriesName != "" {
+		lookup.Series = seriesName
+	}
+
+	series, existing := s.seriesMap[lookup.Series]
+	if !existing {
+		return "", false
+	}
+
+	if lookup.Language == "" {
+		s.GuessEpisodeLanguage(&lookup, series)
+	}
+	if lookup.Language == "" {
+		return "", false
+	}
+
+	title, found := s.EpisodeTitle(series.Name, lookup.Language, lookup.Season, lookup.Episode)
+	if !found {
+		return "", false
+	}
+
+	// titles end up in file names
+	return strings.Replace(title, "/", "-", -1), true
+}
@@ -0,0 +1,71 @@
+package index
+
+import (
+	"github.com/pboehm/series/renamer"
+	. "launchpad.net/gocheck"
+	"path"
+)
+
+func (s *MySuite) TestEpisodeTitleStoredInIndex(c *C) {
+	episode := renamer.Episode{Series: "Shameless US", Season: 1, Episode: 9,
+		Name: "Zwei Brüder", Extension: ".avi", Language: "de"}
+
+	result, err := s.index.AddEpisode(&episode)
+	c.Assert(result.Added, Equals, true)
+	c.Assert(err, IsNil)
+
+	title, found := s.index.EpisodeTitle("Shameless US", "de", 1, 9)
+	c.Assert(found, Equals, true)
+	c.Assert(title, Equals, "Zwei Brüder")
+
+	_, found = s.index.EpisodeTitle("Shameless US", "en", 1, 9)
+	c.Assert(found, Equals, false)
+}
+
+func (s *MySuite) TestDefaultEpisodeNameIsNotStoredAsTitle(c *C) {
+	episode := renamer.Episode{Series: "Shameless US", Season: 1, Episode: 9,
+		Extension: ".avi", Language: "de"}
+	episode.SetDefaultEpisodeName()
+
+	result, _ := s.index.AddEpisode(&episode)
+	c.Assert(result.Added, Equals, true)
+
+	_, found := s.index.EpisodeTitle("Shameless US", "de", 1, 9)
+	c.Assert(found, Equals, false)
+}
+
+func (s *MySuite) TestSetEpisodeTitle(c *C) {
+	c.Assert(s.index.SetEpisodeTitle("Shameless US", "de", 1, 1, "Pilotfolge"), IsNil)
+	c.Assert(s.index.SetEpisodeTitle("Shameless US", "de", 1, 9, "Unbekannt"), NotNil)
+
+	title, _ := s.index.EpisodeTitle("Shameless US", "de", 1, 1)
+	c.Assert(title, Equals, "Pilotfolge")
+
+	file := path.Join(s.dir, "index.xml")
+	c.Assert(s.index.WriteToFile(file), IsNil)
+	index, err := ParseSeriesIndex(file)
+	c.Assert(err, IsNil)
+
+	title, _ = index.EpisodeTitle("Shameless US", "de", 1, 1)
+	c.Assert(title, Equals, "Pilotfolge")
+}
+
+func (s *MySuite) TestLookupEpisodeTitleFromMetadataDirectory(c *C) {
+	createFile(path.Join(s.dir, "Shameless US.json"),
+		`{"de": {"S01E09": "Zwei/Drei Brüder"}, "en": {"S01E09": "Casey Casden"}}`)
+	s.index.AddTitleProvider(MetadataDirectory{Directory: s.dir})
+
+	episode := renamer.Episode{Series: "shameless us", Season: 1, Episode: 9, Language: "de"}
+	title, found := s.index.LookupEpisodeTitle(&episode)
+	c.Assert(found, Equals, true)
+	c.Assert(title, Equals, "Zwei-Drei Brüder")
+	c.Assert(episode.Series, Equals, "shameless us")
+
+	episode = renamer.Episode{Series: "Shameless US", Season: 1, Episode: 10, Language: "de"}
+	_, found = s.index.LookupEpisodeTitle(&episode)
+	c.Assert(found, Equals, false)
+
+	episode = renamer.Episode{Series: "Community", Season: 1, Episode: 1, Language: "de"}
+	_, found = s.index.LookupEpisodeTitle(&episode)
+	c.Assert(found, Equals, false)
+}
@@ -71,7 +71,15 @@ func (e *Episode) HasValidEpisodeName() bool {
 }
 
 func (e *Episode) SetDefaultEpisodeName() {
-	e.Name = fmt.Sprintf("Episode %02d", e.Episode)
+	e.Name = e.defaultEpisodeName()
+}
+
+func (e *Episode) HasDefaultEpisodeName() bool {
+	return e.Name == e.defaultEpisodeName()
+}
+
+func (e *Episode) defaultEpisodeName() string {
+	return fmt.Sprintf("Episode %02d", e.Episode)
 }
 
 func (e *Episode) CanBeRenamed() bool {
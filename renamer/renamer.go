@@ -112,7 +112,7 @@ SplittedLoop:
 			break
 		}
 
-		wordPattern := regexp.MustCompile(fmt.Sprintf("^(?i)%s$", word))
+		wordPattern := regexp.MustCompile(fmt.Sprintf("^(?i)%s$", regexp.QuoteMeta(word)))
 
 		// Check if the current word is a known trashWord
 		for _, trashWord := range TrashWords {
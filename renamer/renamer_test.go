@@ -164,3 +164,24 @@ func (s *MySuite) TestInterestingFiles(c *C) {
 			Commentf("IsInterestingDirEntry(%s) should be %v", key, val))
 	}
 }
+
+// Words are matched literally against the trash words, regexp meta
+// characters in episode names must neither panic nor match other words
+func (s *MySuite) TestTrashWordsWithSpecialCharacters(c *C) {
+	c.Assert(ApplyTrashWordsOnString("Carpe Diem (Director's Cut) German"),
+		Equals, "Carpe Diem (Director's Cut)")
+
+	TestData := map[string]string{
+		"C++ for Beginners German":     "C++ for Beginners",
+		"What? [Extended] German":      "What? [Extended]",
+		"Rock*Star {Part 1} German":    "Rock*Star {Part 1}",
+		"Back\\Slash | Pipe $5 German": "Back\\Slash | Pipe $5",
+		".* German":                    ".*",
+		"Germ.n Dubbed":                "Germ.n",
+	}
+
+	for input, expected := range TestData {
+		c.Assert(ApplyTrashWordsOnString(input), Equals, expected,
+			Commentf("ApplyTrashWordsOnString(%s) should be %s", input, expected))
+	}
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"github.com/pboehm/series/config"
+	"github.com/pboehm/series/hooks"
 	"github.com/pboehm/series/util"
 	"github.com/spf13/cobra"
 	"log"
 	"os"
 	"path"
+	"strings"
 )
 
 var LOG = log.New(os.Stderr, "", 0)
@@ -17,35 +21,192 @@ func HandleError(err error) {
 	}
 }
 
-var configDirectory, configFile, customEpisodeDirectory string
+var configDirectory, dataDirectory, stateDirectory string
+var configFile, configProfile, customEpisodeDirectory string
+var configOverrides []string
+var verbose bool
 var defaultConfig, appConfig config.Config
+var configSources config.Sources
+
+// setupDirectories resolves the XDG base directories used by series: the
+// config file lives in the config directory, the index, its backups and the
+// metadata in the data directory and the files changing on every run in the
+// state directory
+func setupDirectories() {
+	for _, directory := range []struct {
+		name   string
+		target *string
+		base   func() (string, error)
+	}{
+		{"config", &configDirectory, util.ConfigHome},
+		{"data", &dataDirectory, util.DataHome},
+		{"state", &stateDirectory, util.StateHome},
+	} {
+		base, err := directory.base()
+		if err != nil {
+			HandleError(errors.New(fmt.Sprintf("the %s directory could not be determined: %s", directory.name, err)))
+		}
+		*directory.target = path.Join(base, "series")
+	}
+
+	// the index and the state files are written into these directories
+	for _, directory := range []string{dataDirectory, stateDirectory} {
+		HandleError(os.MkdirAll(directory, 0755))
+	}
+}
+
+// migrateLegacyDirectory moves the files from ~/.series, which has been used
+// before the XDG base directories, to their new locations
+func migrateLegacyDirectory(home string) {
+	migration := config.LegacyMigration{
+		Directory:  path.Join(home, ".series"),
+		ConfigFile: configFile,
+		Targets: map[string]string{
+			"config.json":     configFile,
+			"index.xml":       path.Join(dataDirectory, "index.xml"),
+			"backups":         path.Join(dataDirectory, "backups"),
+			"metadata":        path.Join(dataDirectory, "metadata"),
+			"pending.json":    path.Join(stateDirectory, "pending.json"),
+			"seen_links.json": path.Join(stateDirectory, "seen_links.json"),
+		},
+		DefaultDirectory: configDirectory,
+	}
+
+	if !migration.Needed() {
+		return
+	}
+
+	// a directory under version control is kept as it is, the legacy config
+	// file references the files in it
+	if vcs := migration.VersionControlled(); vcs != "" {
+		LOG.Printf("!!! %s is under version control (%s) and is not moved to the XDG base "+
+			"directories, move its files yourself to migrate\n", migration.Directory, vcs)
+		configFile = path.Join(migration.Directory, "config.json")
+		return
+	}
+
+	moves, err := migration.Run()
+	for source, target := range moves {
+		LOG.Printf("!!! Moved %s to %s\n", source, target)
+	}
+	if err != nil {
+		HandleError(errors.New(fmt.Sprintf("migrating %s failed: %s", migration.Directory, err)))
+	}
+}
 
 func setupConfig() {
-	configDirectory = path.Join(util.HomeDirectory(), ".series")
-	configFile = path.Join(configDirectory, "config.json")
+	setupDirectories()
+	home, homeErr := util.HomeDirectory()
+
+	if configFile == "" {
+		configFile = os.Getenv("SERIES_CONFIG_FILE")
+	}
+	if configFile == "" {
+		configFile = path.Join(configDirectory, "config.json")
+		if homeErr == nil {
+			migrateLegacyDirectory(home)
+		}
+	}
+
+	episodeDirectory := ""
+	if homeErr == nil {
+		episodeDirectory = path.Join(home, "Downloads")
+	}
 
 	defaultConfig = config.Config{
-		EpisodeDirectory: path.Join(util.HomeDirectory(), "Downloads"),
-		IndexFile:        path.Join(configDirectory, "index.xml"),
-		ScriptExtractors: []string{},
+		EpisodeDirectory:     episodeDirectory,
+		IndexFile:            path.Join(dataDirectory, "index.xml"),
+		IndexBackupDirectory: path.Join(dataDirectory, "backups"),
+		IndexBackupCount:     10,
+		IndexBackupDays:      7,
+		MetadataDirectory:    path.Join(dataDirectory, "metadata"),
+		ScriptExtractors:     []config.ScriptExtractor{},
+		SeriesNameRules:      []config.SeriesNameRule{},
+		ExtractorOrder:       []string{"filesystem", "regex", "mapping", "script"},
+		UnknownSeriesPolicy:  config.UnknownSeriesIgnore,
+		PendingFile:          path.Join(stateDirectory, "pending.json"),
+		HookTimeout:          300,
+		SeenLinksFile:        path.Join(stateDirectory, "seen_links.json"),
+		RewriteConfig:        true,
+	}
+
+	if configProfile == "" {
+		configProfile = os.Getenv("SERIES_PROFILE")
+	}
+
+	overrides := configOverrides
+	if customEpisodeDirectory != "" {
+		overrides = append([]string{"EpisodeDirectory=" + customEpisodeDirectory}, overrides...)
 	}
 
-	appConfig = config.GetConfig(configFile, defaultConfig)
+	var err error
+	appConfig, configSources, err = config.LoadConfig(configFile, defaultConfig, configProfile, os.Environ(), overrides)
+	HandleError(err)
+	warnAboutReadableSecrets()
+
+	// secrets given by the environment are only passed to actions which
+	// ask for them
+	hooks.InheritedEnviron = func() []string {
+		return config.WithoutSecretEnviron(os.Environ())
+	}
+	HandleError(hooks.Validate(appConfig.Hooks))
+	HandleError(hooks.ValidateWebhooks(appConfig.Webhooks))
+}
+
+// configEnviron returns the environment variables which let commands started
+// by series use the same config file and profile
+func configEnviron() []string {
+	return []string{
+		fmt.Sprintf("SERIES_CONFIG_FILE=%s", configFile),
+		fmt.Sprintf("SERIES_PROFILE=%s", configProfile),
+	}
+}
+
+// warnAboutReadableSecrets warns when secrets are stored in a config file
+// which can be read by everyone
+func warnAboutReadableSecrets() {
+	if !config.WorldReadable(configFile) {
+		return
+	}
+
+	var inFile []string
+	for _, name := range config.PlaintextSecrets(appConfig) {
+		if configSources[name] == config.SourceFile {
+			inFile = append(inFile, name)
+		}
+	}
+
+	if len(inFile) > 0 {
+		LOG.Printf("!!! %s is readable by everyone but contains %s, run: chmod 600 %s\n",
+			configFile, strings.Join(inFile, ", "), configFile)
+	}
 }
 
 var seriesCmd = &cobra.Command{
 	Use: "series",
 	Run: renameAndIndexHandler,
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		waitForWebhooks()
+		printHookSummary()
+	},
 }
 
 func init() {
+	cobra.OnInitialize(setupConfig)
+
+	seriesCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "",
+		"The config file to use. (Default: $SERIES_CONFIG_FILE or $XDG_CONFIG_HOME/series/config.json)")
+	seriesCmd.PersistentFlags().StringVar(&configProfile, "profile", "",
+		"The config profile to use. (Default: $SERIES_PROFILE)")
+	seriesCmd.PersistentFlags().StringArrayVar(&configOverrides, "set", []string{},
+		"Override a config value for this run, e.g. --set IndexBackupCount=20")
 	seriesCmd.PersistentFlags().StringVarP(&customEpisodeDirectory, "dir", "d", "",
 		"The directory which includes the episodes. (Overrides the config value)")
+	seriesCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false,
+		"Log how episodes are matched against the index.")
 }
 
 func main() {
-	setupConfig()
-
-	seriesCmd.AddCommand(renameAndIndexCmd, indexCmd, streamsCmd)
+	seriesCmd.AddCommand(renameAndIndexCmd, indexCmd, libraryCmd, streamsCmd, explainCmd, pendingCmd, configCmd)
 	seriesCmd.Execute()
 }
@@ -5,14 +5,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/pboehm/series/config"
 	"strconv"
 	"strings"
 )
 
 type WatchedSeries struct {
+	Provider          Provider
 	Series            *Series
 	SeriesNameInIndex string
 	SeriesLanguages   map[string]int
+	Status            string
 }
 
 type Identifier struct {
@@ -23,6 +26,18 @@ type Identifier struct {
 	Season      int    `json:"season"`
 	Episode     int    `json:"episode"`
 	EpisodeName string `json:"episode_name"`
+
+	// Provider is empty for the default provider, so that identifiers
+	// created before there were several providers stay valid
+	Provider string `json:"provider,omitempty"`
+}
+
+// ProviderName returns the name of the provider the identifier belongs to
+func (i *Identifier) ProviderName() string {
+	if i.Provider == "" {
+		return config.DefaultStreamsProvider
+	}
+	return i.Provider
 }
 
 func (i *Identifier) AsString() (string, error) {
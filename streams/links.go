@@ -9,9 +9,10 @@ import (
 )
 
 type LinkSetEntryLink struct {
-	Id     string `json:"id"`
-	Hoster string `json:"hoster"`
-	Link   string `json:"link"`
+	Id       string `json:"id"`
+	Provider string `json:"provider"`
+	Hoster   string `json:"hoster"`
+	Link     string `json:"link"`
 }
 
 type LinkSetEntry struct {
@@ -26,31 +27,86 @@ type LinkSetEntry struct {
 	Links       []*LinkSetEntryLink `json:"links"`
 }
 
+// LinkSet contains the links for unwatched episodes. Links of the same
+// episode from several providers are merged into one entry.
 type LinkSet struct {
 	config       config.Config
-	streams      *Streams
+	providers    []Provider
 	index        *index.SeriesIndex
 	episodeLinks []*LinkSetEntry
+	errors       []*LinkSetError
 }
 
-func NewLinkSet(config config.Config, streams *Streams, index *index.SeriesIndex) *LinkSet {
+// LinkSetError describes why the links of a series or all series of a
+// provider could not be fetched. Errors without a provider prevented fetching
+// any links.
+type LinkSetError struct {
+	Provider string `json:"provider,omitempty"`
+	Series   string `json:"series,omitempty"`
+	Error    string `json:"error"`
+}
+
+type seriesLinks struct {
+	provider int
+	entries  []*LinkSetEntry
+	err      *LinkSetError
+}
+
+func NewLinkSet(config config.Config, providers []Provider, index *index.SeriesIndex) *LinkSet {
 	return &LinkSet{
-		config:  config,
-		streams: streams,
-		index:   index,
+		config:    config,
+		providers: providers,
+		index:     index,
 	}
 }
 
 func (l *LinkSet) GrabLinksFor(watched []WatchedSeries) {
-	resultsChannel := make(chan []*LinkSetEntry, len(watched))
-
+	// links of paused series are not fetched
+	var fetchable []WatchedSeries
 	for _, series := range watched {
+		if series.Status != index.StatusPaused {
+			fetchable = append(fetchable, series)
+		}
+	}
+
+	resultsChannel := make(chan seriesLinks, len(fetchable))
+
+	for _, series := range fetchable {
 		go l.grabLinksForSeries(series, resultsChannel)
 	}
 
-	for range watched {
+	// entries are merged in the order of the providers, so that the entries
+	// of the first provider determine the ids
+	resultsByProvider := make([][]*LinkSetEntry, len(l.providers))
+	for range fetchable {
 		results := <-resultsChannel
-		l.episodeLinks = append(l.episodeLinks, results...)
+		resultsByProvider[results.provider] = append(resultsByProvider[results.provider], results.entries...)
+		if results.err != nil {
+			l.errors = append(l.errors, results.err)
+		}
+	}
+
+	sort.Slice(l.errors, func(i, j int) bool {
+		if l.errors[i].Series != l.errors[j].Series {
+			return l.errors[i].Series < l.errors[j].Series
+		}
+		return l.errors[i].Provider < l.errors[j].Provider
+	})
+
+	merged := map[string]*LinkSetEntry{}
+	for _, results := range resultsByProvider {
+		for _, entry := range results {
+			key := fmt.Sprintf("%s|%s|%d|%d", entry.Series, entry.Language, entry.Season, entry.Episode)
+
+			if existing, ok := merged[key]; ok {
+				existing.Links = append(existing.Links, entry.Links...)
+				sortLinks(existing.Links)
+				continue
+			}
+
+			merged[key] = entry
+			l.episodeLinks = append(l.episodeLinks, entry)
+		}
 	}
 
 	sort.Slice(l.episodeLinks, func(i, j int) bool {
@@ -73,15 +129,47 @@ func (l *LinkSet) GrabLinksFor(watched []WatchedSeries) {
 	})
 }
 
-func (l *LinkSet) grabLinksForSeries(series WatchedSeries, results chan []*LinkSetEntry) {
+func (l *LinkSet) providerIndex(provider Provider) int {
+	for i, p := range l.providers {
+		if p == provider {
+			return i
+		}
+	}
+	return 0
+}
+
+// grabLinksForSeries sends the entries for the unwatched episodes of the series
+// and the error which stopped fetching them. The entries found before the
+// error are sent as well.
+func (l *LinkSet) grabLinksForSeries(series WatchedSeries, results chan seriesLinks) {
 	var entries []*LinkSetEntry
 
-	seasons := l.streams.Seasons(series.Series)
+	result := func(err error) seriesLinks {
+		links := seriesLinks{provider: l.providerIndex(series.Provider), entries: entries}
+		if err != nil {
+			links.err = &LinkSetError{
+				Provider: series.Provider.Name(),
+				Series:   series.SeriesNameInIndex,
+				Error:    err.Error(),
+			}
+		}
+		return links
+	}
+
+	seasons, err := series.Provider.Seasons(series.Series)
+	if err != nil {
+		results <- result(err)
+		return
+	}
 
 	for _, season := range seasons {
 		var existingEpisodes, newEpisodes = 0, 0
 
-		episodes := l.streams.Episodes(series.Series, season)
+		episodes, err := series.Provider.Episodes(series.Series, season)
+		if err != nil {
+			results <- result(err)
+			return
+		}
 
 		for _, episode := range episodes {
 			for language, languageInt := range series.SeriesLanguages {
@@ -105,7 +193,7 @@ func (l *LinkSet) grabLinksForSeries(series WatchedSeries, results chan []*LinkS
 		}
 	}
 
-	results <- entries
+	results <- result(nil)
 }
 
 func (l *LinkSet) buildEntry(series WatchedSeries, language string, episode *Episode, links []*Link) *LinkSetEntry {
@@ -120,21 +208,21 @@ func (l *LinkSet) buildEntry(series WatchedSeries, language string, episode *Epi
 
 	identifier := NewIdentifier(series.SeriesNameInIndex, series.Series.Link, series.Series.Id, language,
 		episode.Season, episode.Episode, episodeName)
+	if series.Provider.Name() != config.DefaultStreamsProvider {
+		identifier.Provider = series.Provider.Name()
+	}
 
 	var entryLinks []*LinkSetEntryLink
 	for _, link := range links {
 		linkIdentifier, _ := NewLinkIdentifier(identifier, link.ID)
 		entryLinks = append(entryLinks, &LinkSetEntryLink{
-			Id:     linkIdentifier,
-			Hoster: link.Hoster,
-			Link:   l.streams.LinkUrl(link.ID),
+			Id:       linkIdentifier,
+			Provider: series.Provider.Name(),
+			Hoster:   link.Hoster,
+			Link:     series.Provider.LinkUrl(link.ID),
 		})
 	}
-
-	// TODO replace by real hoster selection
-	sort.Slice(entryLinks[:], func(i, j int) bool {
-		return strings.Index(entryLinks[i].Hoster, "HD") > strings.Index(entryLinks[j].Hoster, "HD")
-	})
+	sortLinks(entryLinks)
 
 	idString, _ := identifier.AsString()
 
@@ -151,8 +239,36 @@ func (l *LinkSet) buildEntry(series WatchedSeries, language string, episode *Epi
 	}
 }
 
+func sortLinks(links []*LinkSetEntryLink) {
+	// TODO replace by real hoster selection
+	sort.SliceStable(links, func(i, j int) bool {
+		return strings.Index(links[i].Hoster, "HD") > strings.Index(links[j].Hoster, "HD")
+	})
+}
+
+// AddProviderError records that the series of the provider could not be
+// listed
+func (l *LinkSet) AddProviderError(provider Provider, err error) {
+	l.errors = append(l.errors, &LinkSetError{Provider: provider.Name(), Error: err.Error()})
+}
+
+// AddError records a failure which prevented fetching the links of all
+// providers, like a missing index
+func (l *LinkSet) AddError(err error) {
+	l.errors = append(l.errors, &LinkSetError{Error: err.Error()})
+}
+
+// Errors returns the failures which occurred while fetching the links
+func (l *LinkSet) Errors() []*LinkSetError {
+	//noinspection GoPreferNilSlice
+	errors := []*LinkSetError{}
+	return append(errors, l.errors...)
+}
+
 func (l *LinkSet) Entries() []*LinkSetEntry {
-	return l.episodeLinks
+	//noinspection GoPreferNilSlice
+	entries := []*LinkSetEntry{}
+	return append(entries, l.episodeLinks...)
 }
 
 func (l *LinkSet) GroupedEntries() map[string][]*LinkSetEntry {
@@ -0,0 +1,203 @@
+package streams
+
+import (
+	"errors"
+	"github.com/pboehm/series/config"
+	"github.com/pboehm/series/index"
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"path"
+	"testing"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+var _ = Suite(&MySuite{})
+
+type MySuite struct {
+	dir   string
+	index *index.SeriesIndex
+}
+
+const testIndex = `<?xml version="1.0" encoding="utf-8"?>
+<seriesindex version="3">
+    <series name="Breaking Bad">
+        <episodes lang="de">
+            <episode name="S01E01 - Pilot.mkv" />
+        </episodes>
+    </series>
+    <series name="Community">
+        <episodes lang="de">
+            <episode name="S01E01 - Zurück aufs College.avi" />
+        </episodes>
+    </series>
+</seriesindex>
+`
+
+func (s *MySuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+
+	indexFile := path.Join(s.dir, "index.xml")
+	c.Assert(ioutil.WriteFile(indexFile, []byte(testIndex), 0644), IsNil)
+
+	var err error
+	s.index, err = index.ParseSeriesIndex(indexFile)
+	c.Assert(err, IsNil)
+}
+
+// fakeProvider serves the episodes of a single series per season, seasons in
+// failingSeasons return an error
+type fakeProvider struct {
+	name           string
+	seasons        []int
+	seasonsErr     error
+	episodes       map[int][]*Episode
+	failingSeasons map[int]error
+}
+
+func (f *fakeProvider) Name() string { return f.name }
+
+func (f *fakeProvider) AvailableSeries() ([]*Series, error) { return nil, nil }
+
+func (f *fakeProvider) Seasons(series *Series) ([]int, error) { return f.seasons, f.seasonsErr }
+
+func (f *fakeProvider) Episodes(series *Series, season int) ([]*Episode, error) {
+	if err, ok := f.failingSeasons[season]; ok {
+		return nil, err
+	}
+	return f.episodes[season], nil
+}
+
+func (f *fakeProvider) LanguageId(language string) (int, bool) {
+	id, ok := defaultLanguageIds[language]
+	return id, ok
+}
+
+func (f *fakeProvider) LinkUrl(linkId int) string { return f.name + "/redirect" }
+
+func (f *fakeProvider) Login() (string, error) { return "session", nil }
+
+func (f *fakeProvider) ResolveLink(linkId int, session string) (string, error) { return "", nil }
+
+func episode(id, season, number int, hosters ...string) *Episode {
+	episode := &Episode{ID: id, Season: season, Episode: number, German: "Folge"}
+	for i, hoster := range hosters {
+		episode.Links = append(episode.Links, &Link{ID: id*10 + i, Hoster: hoster, Language: 1})
+	}
+	return episode
+}
+
+func watched(provider Provider, seriesName string) WatchedSeries {
+	return WatchedSeries{
+		Provider:          provider,
+		Series:            &Series{Id: 1, Name: seriesName, Link: "series"},
+		SeriesNameInIndex: seriesName,
+		SeriesLanguages:   map[string]int{"de": 1},
+	}
+}
+
+func (s *MySuite) TestMergeLinksOfProviders(c *C) {
+	first := &fakeProvider{name: config.DefaultStreamsProvider, seasons: []int{1}, episodes: map[int][]*Episode{
+		1: {episode(1, 1, 1, "Vivo"), episode(2, 1, 2, "Vivo")},
+	}}
+	second := &fakeProvider{name: "mirror", seasons: []int{1}, episodes: map[int][]*Episode{
+		1: {episode(7, 1, 2, "Streamtape", "VOE HD"), episode(8, 1, 3, "Vivo")},
+	}}
+
+	linkSet := NewLinkSet(config.Config{}, []Provider{first, second}, s.index)
+	linkSet.GrabLinksFor([]WatchedSeries{watched(second, "Breaking Bad"), watched(first, "Breaking Bad")})
+
+	entries := linkSet.Entries()
+	c.Assert(entries, HasLen, 2)
+	c.Assert(linkSet.Errors(), HasLen, 0)
+
+	// the entry of the first provider is kept and gets the links of the
+	// second one, HD links are sorted first
+	c.Assert(entries[0].Episode, Equals, 2)
+	c.Assert(entries[0].EpisodeId, Equals, 2)
+	c.Assert(entries[0].Links, HasLen, 3)
+	c.Assert(entries[0].Links[0].Hoster, Equals, "VOE HD")
+	c.Assert(entries[0].Links[0].Provider, Equals, "mirror")
+	c.Assert(entries[0].Links[1].Hoster, Equals, "Vivo")
+	c.Assert(entries[0].Links[1].Provider, Equals, config.DefaultStreamsProvider)
+	c.Assert(entries[0].Links[2].Hoster, Equals, "Streamtape")
+
+	identifier, err := IdentifierFromString(entries[0].Id)
+	c.Assert(err, IsNil)
+	c.Assert(identifier.ProviderName(), Equals, config.DefaultStreamsProvider)
+
+	c.Assert(entries[1].Episode, Equals, 3)
+	c.Assert(entries[1].Links, HasLen, 1)
+	c.Assert(entries[1].Links[0].Provider, Equals, "mirror")
+}
+
+func (s *MySuite) TestSortLinksKeepsOrderOfEqualLinks(c *C) {
+	links := []*LinkSetEntryLink{
+		{Hoster: "Vivo", Provider: "a"},
+		{Hoster: "Streamtape", Provider: "b"},
+		{Hoster: "HD Stream", Provider: "c"},
+		{Hoster: "Vivo", Provider: "d"},
+	}
+
+	sortLinks(links)
+
+	var providers []string
+	for _, link := range links {
+		providers = append(providers, link.Provider)
+	}
+	c.Assert(providers, DeepEquals, []string{"c", "a", "b", "d"})
+}
+
+func (s *MySuite) TestPartialEntriesOnError(c *C) {
+	provider := &fakeProvider{
+		name:           config.DefaultStreamsProvider,
+		seasons:        []int{1, 2},
+		episodes:       map[int][]*Episode{1: {episode(1, 1, 1, "Vivo"), episode(2, 1, 2, "Vivo")}},
+		failingSeasons: map[int]error{2: errors.New("season 2 failed")},
+	}
+
+	linkSet := NewLinkSet(config.Config{}, []Provider{provider}, s.index)
+	linkSet.GrabLinksFor([]WatchedSeries{watched(provider, "Breaking Bad")})
+
+	// S01E01 is watched already, S01E02 has been fetched before the error
+	entries := linkSet.Entries()
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].Episode, Equals, 2)
+
+	c.Assert(linkSet.Errors(), DeepEquals, []*LinkSetError{
+		{Provider: config.DefaultStreamsProvider, Series: "Breaking Bad", Error: "season 2 failed"},
+	})
+}
+
+func (s *MySuite) TestErrorsOrdering(c *C) {
+	failed := errors.New("failed")
+	first := &fakeProvider{name: "b", seasonsErr: failed}
+	second := &fakeProvider{name: "a", seasonsErr: failed}
+	unavailable := &fakeProvider{name: "c"}
+
+	linkSet := NewLinkSet(config.Config{}, []Provider{first, second, unavailable}, s.index)
+	linkSet.AddProviderError(unavailable, errors.New("listing failed"))
+	linkSet.GrabLinksFor([]WatchedSeries{
+		watched(first, "Community"), watched(second, "Community"),
+		watched(first, "Breaking Bad"), watched(second, "Breaking Bad"),
+	})
+
+	var order []string
+	for _, err := range linkSet.Errors() {
+		order = append(order, err.Series+"|"+err.Provider)
+	}
+	c.Assert(order, DeepEquals, []string{"|c", "Breaking Bad|a", "Breaking Bad|b", "Community|a", "Community|b"})
+
+	// the returned errors are a copy
+	linkSet.Errors()[0] = nil
+	c.Assert(linkSet.Errors()[0], NotNil)
+}
+
+func (s *MySuite) TestAddError(c *C) {
+	linkSet := NewLinkSet(config.Config{}, nil, nil)
+	linkSet.AddError(errors.New("index missing"))
+
+	c.Assert(linkSet.Entries(), HasLen, 0)
+	c.Assert(linkSet.Errors(), DeepEquals, []*LinkSetError{{Error: "index missing"}})
+}
@@ -0,0 +1,31 @@
+package streams
+
+// Provider is a streaming site offering links to episodes of series
+type Provider interface {
+	// Name identifies the provider in identifiers and the config
+	Name() string
+
+	AvailableSeries() ([]*Series, error)
+	Seasons(series *Series) ([]int, error)
+	Episodes(series *Series, season int) ([]*Episode, error)
+
+	// LanguageId returns the id used for the language in links
+	LanguageId(language string) (int, bool)
+
+	// LinkUrl returns the URL redirecting to the video of the link
+	LinkUrl(linkId int) string
+
+	// Login returns a session which is required to resolve links
+	Login() (string, error)
+	ResolveLink(linkId int, session string) (string, error)
+}
+
+// FindProvider returns the provider with the name
+func FindProvider(providers []Provider, name string) (Provider, bool) {
+	for _, provider := range providers {
+		if provider.Name() == name {
+			return provider, true
+		}
+	}
+	return nil, false
+}
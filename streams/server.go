@@ -3,6 +3,7 @@ package streams
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/pboehm/series/config"
+	"github.com/pboehm/series/hooks"
 	"sort"
 	"strconv"
 )
@@ -23,10 +24,11 @@ type API struct {
 	HtmlContent         func() []byte
 	LinkSet             func() *LinkSet
 	LinkSetRefresh      func()
-	MarkWatched         func([]string) ([]string, []string)
+	MarkWatched         func([]string) ([]string, []string, error)
 	ExecuteLinkAction   func(config.StreamAction, *Identifier, int) *Job
 	ExecuteGlobalAction func(config.StreamAction) *Job
-	ResolveLink         func(linkId int) (string, error)
+	ResolveLink         func(provider string, linkId int) (string, error)
+	HookExecutions      func() []hooks.Execution
 }
 
 //noinspection ALL
@@ -39,26 +41,33 @@ func (a *API) Run(listen string) error {
 		entriesReady := false
 		//noinspection GoPreferNilSlice
 		entries := []*LinkSetEntry{}
+		//noinspection GoPreferNilSlice
+		errors := []*LinkSetError{}
 
 		linkSet := a.LinkSet()
 		if linkSet != nil {
 			entriesReady = true
 			entries = linkSet.Entries()
+			errors = linkSet.Errors()
 		}
 
 		c.JSON(200, gin.H{
-			"ready": entriesReady,
-			"links": entries,
+			"ready":  entriesReady,
+			"links":  entries,
+			"errors": errors,
 		})
 	})
 	r.GET("/api/links/grouped", func(c *gin.Context) {
 		entriesReady := false
 		entries := map[string][]*LinkSetEntry{}
+		//noinspection GoPreferNilSlice
+		errors := []*LinkSetError{}
 
 		linkSet := a.LinkSet()
 		if linkSet != nil {
 			entriesReady = true
 			entries = linkSet.GroupedEntries()
+			errors = linkSet.Errors()
 		}
 
 		//noinspection GoPreferNilSlice
@@ -78,8 +87,20 @@ func (a *API) Run(listen string) error {
 		})
 
 		c.JSON(200, gin.H{
-			"ready": entriesReady,
-			"links": grouped,
+			"ready":  entriesReady,
+			"links":  grouped,
+			"errors": errors,
+		})
+	})
+	r.GET("/api/hooks", func(c *gin.Context) {
+		//noinspection GoPreferNilSlice
+		executions := []hooks.Execution{}
+		if a.HookExecutions != nil {
+			executions = a.HookExecutions()
+		}
+
+		c.JSON(200, gin.H{
+			"executions": executions,
 		})
 	})
 	r.POST("/api/links/refresh", func(c *gin.Context) {
@@ -100,7 +121,15 @@ func (a *API) Run(listen string) error {
 			return
 		}
 
-		successes, failures := a.MarkWatched(episodeIds)
+		successes, failures, err := a.MarkWatched(episodeIds)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"error":     err.Error(),
+				"successes": successes,
+				"failures":  failures,
+			})
+			return
+		}
 
 		c.JSON(200, gin.H{
 			"successes": successes,
@@ -108,7 +137,15 @@ func (a *API) Run(listen string) error {
 		})
 	})
 	r.POST("/api/link/resolve/:linkId", func(c *gin.Context) {
-		linkId, err := strconv.Atoi(c.Param("linkId"))
+		// plain link ids belong to the default provider
+		provider := config.DefaultStreamsProvider
+
+		identifier, linkId, err := LinkIdentifierFromString(c.Param("linkId"))
+		if err == nil {
+			provider = identifier.ProviderName()
+		} else {
+			linkId, err = strconv.Atoi(c.Param("linkId"))
+		}
 		if err != nil {
 			c.JSON(400, gin.H{
 				"error": err.Error(),
@@ -116,7 +153,7 @@ func (a *API) Run(listen string) error {
 			return
 		}
 
-		link, err := a.ResolveLink(linkId)
+		link, err := a.ResolveLink(provider, linkId)
 		if err != nil {
 			c.JSON(400, gin.H{
 				"error": err.Error(),
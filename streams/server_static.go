@@ -154,7 +154,7 @@ var ServerStaticHtml = `
                             <div class="hoster">
                                 <select id="select-[[ __id ]]">
                                     [[#links]]
-                                    <option value="[[ id ]]" data-link="[[ link ]]">[[ hoster ]]</option>
+                                    <option value="[[ id ]]" data-link="[[ link ]]">[[ hoster ]] ([[ provider ]])</option>
                                     [[/links]]
                                 </select>
                             </div>
@@ -384,6 +384,12 @@ var ServerStaticHtml = `
                 refreshButton.addClass("hidden");
             }
 
+            (success.errors || []).forEach(function (error) {
+                var subject = error.series ? error.series + " (" + error.provider + ")" : error.provider;
+                var message = subject ? subject + ": " + error.error : error.error;
+                M.toast({html: $("<span>").text(message).html(), displayLength: 10000});
+            });
+
             var groups = success.links;
             groups.forEach(function (group) {
                 var episodes = group["episodes"];
@@ -416,6 +422,9 @@ var ServerStaticHtml = `
         }).then(function (success) {
             button.text = originalText;
             button.classList.remove("disabled");
+            if (success.error) {
+                M.toast({html: $("<span>").text(success.error).html(), displayLength: 10000});
+            }
             loadLinks();
         }, function (error) {
             console.log(error);
@@ -1,13 +1,15 @@
 package streams
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/imroc/req"
 	"github.com/pboehm/series/config"
-	"log"
 	"net/http"
+	"net/url"
 	"sort"
+	"strings"
 )
 
 type Series struct {
@@ -16,10 +18,6 @@ type Series struct {
 	Link string `json:"link"`
 }
 
-func (s Series) AbsoluteLink() string {
-	return absoluteUrl(fmt.Sprintf("/serie/stream/%s", s.Link))
-}
-
 type Link struct {
 	ID          int    `json:"id"`
 	Link        string `json:"link"`
@@ -79,98 +77,112 @@ type LoginResponse struct {
 	Username string `json:"username"`
 }
 
+// DefaultBaseURL is the base URL of the provider configured by the Streams*
+// settings
+var DefaultBaseURL = func(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < len(r)/2; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}("ot.s//:sptth")
+
+// defaultLanguageIds is used when the provider has no Languages configured
+var defaultLanguageIds = map[string]int{"de": 1, "en": 2}
+
+// Streams is a Provider for sites offering the API of the default site
 type Streams struct {
-	Config   config.Config
+	Provider config.StreamsProvider
 	requests *req.Req
 }
 
-func NewStreams(config config.Config) *Streams {
+func NewStreams(provider config.StreamsProvider) *Streams {
 	requests := req.New()
 	requests.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
 		return http.ErrUseLastResponse
 	}
 
 	return &Streams{
-		Config:   config,
+		Provider: provider,
 		requests: requests,
 	}
 }
 
-func (s *Streams) AvailableSeries() []*Series {
-	header := req.Header{
-		"Accept": "application/json",
+func (s *Streams) Name() string {
+	return s.Provider.Name
+}
+
+func (s *Streams) LanguageId(language string) (int, bool) {
+	languageIds := s.Provider.Languages
+	if len(languageIds) == 0 {
+		languageIds = defaultLanguageIds
 	}
+
+	id, ok := languageIds[language]
+	return id, ok
+}
+
+func (s *Streams) SeriesUrl(series *Series) string {
+	return s.absoluteUrl(fmt.Sprintf("/serie/stream/%s", series.Link))
+}
+
+func (s *Streams) AvailableSeries() ([]*Series, error) {
 	param := req.QueryParam{
-		"key":      s.Config.StreamsAPIToken,
+		"key":      s.Provider.APIToken,
 		"extended": "0",
 		"category": "0",
 	}
 
-	r, err := s.requests.Get(absoluteUrl("/api/v1/series/list"), header, param)
-	if err != nil {
-		log.Fatal(err)
-	}
-
 	var parsedResponse AvailableSeriesResponse
-	r.ToJSON(&parsedResponse)
+	if err := s.getJSON("/api/v1/series/list", param, &parsedResponse); err != nil {
+		return nil, err
+	}
 
-	return parsedResponse.Series
+	return parsedResponse.Series, nil
 }
 
-func (s *Streams) Seasons(series *Series) []int {
-	header := req.Header{
-		"Accept": "application/json",
-	}
+func (s *Streams) Seasons(series *Series) ([]int, error) {
 	param := req.QueryParam{
-		"key":    s.Config.StreamsAPIToken,
+		"key":    s.Provider.APIToken,
 		"series": series.Id,
 	}
 
-	r, err := s.requests.Get(absoluteUrl("/api/v1/series/get"), header, param)
-	if err != nil {
-		log.Fatal(err)
-	}
-
 	var parsedResponse SeriesWithSeasonsResponse
-	r.ToJSON(&parsedResponse)
+	if err := s.getJSON("/api/v1/series/get", param, &parsedResponse); err != nil {
+		return nil, err
+	}
 
 	seasons := parsedResponse.Seasons
 	sort.Sort(sort.Reverse(sort.IntSlice(seasons)))
-	return seasons
+	return seasons, nil
 }
 
-func (s *Streams) Episodes(series *Series, season int) []*Episode {
-	header := req.Header{
-		"Accept": "application/json",
-	}
+func (s *Streams) Episodes(series *Series, season int) ([]*Episode, error) {
 	param := req.QueryParam{
-		"key":    s.Config.StreamsAPIToken,
+		"key":    s.Provider.APIToken,
 		"series": series.Id,
 		"season": season,
 	}
 
-	r, err := s.requests.Get(absoluteUrl("/api/v1/series/get"), header, param)
-	if err != nil {
-		log.Fatal(err)
-	}
-
 	var parsedResponse SeriesWithEpisodesResponse
-	r.ToJSON(&parsedResponse)
+	if err := s.getJSON("/api/v1/series/get", param, &parsedResponse); err != nil {
+		return nil, err
+	}
 
-	return parsedResponse.Episodes
+	return parsedResponse.Episodes, nil
 }
 
-func (s *Streams) Login(email string, password string) (string, error) {
-	body := req.Param{"email": email, "password": password}
+func (s *Streams) Login() (string, error) {
+	body := req.Param{"email": s.Provider.AccountEmail, "password": s.Provider.AccountPassword}
 
-	url := absoluteUrl(fmt.Sprintf("/api/v1/account/login?key=%s", s.Config.StreamsAPIToken))
-	r, err := s.requests.Post(url, body)
+	path := "/api/v1/account/login"
+	r, err := s.requests.Post(s.absoluteUrl(path), body, req.QueryParam{"key": s.Provider.APIToken})
 	if err != nil {
-		return "", err
+		return "", requestError(path, err)
 	}
 
 	var response LoginResponse
-	if err = r.ToJSON(&response); err != nil {
+	if err = decodeResponse(path, r, &response); err != nil {
 		return "", err
 	}
 
@@ -185,7 +197,7 @@ func (s *Streams) ResolveLink(linkId int, session string) (string, error) {
 
 	r, err := s.requests.Head(s.LinkUrl(linkId), header)
 	if err != nil {
-		return "", err
+		return "", requestError(fmt.Sprintf("/api/v1/stream/%d", linkId), err)
 	}
 
 	response := r.Response()
@@ -198,17 +210,77 @@ func (s *Streams) ResolveLink(linkId int, session string) (string, error) {
 }
 
 func (s *Streams) LinkUrl(linkId int) string {
-	return absoluteUrl(fmt.Sprintf("/api/v1/stream/%d?key=%s", linkId, s.Config.StreamsAPIToken))
+	return s.absoluteUrl(fmt.Sprintf("/api/v1/stream/%d?key=%s", linkId, s.Provider.APIToken))
 }
 
-func absoluteUrl(path string) string {
-	rev := func(s string) string {
-		r := []rune(s)
-		for i, j := 0, len(r)-1; i < len(r)/2; i, j = i+1, j-1 {
-			r[i], r[j] = r[j], r[i]
-		}
-		return string(r)
+func (s *Streams) absoluteUrl(path string) string {
+	return strings.TrimSuffix(s.Provider.BaseURL, "/") + path
+}
+
+// getJSON requests the path of the API and decodes the JSON response
+func (s *Streams) getJSON(path string, param req.QueryParam, target interface{}) error {
+	header := req.Header{
+		"Accept": "application/json",
+	}
+
+	r, err := s.requests.Get(s.absoluteUrl(path), header, param)
+	if err != nil {
+		return requestError(path, err)
+	}
+
+	return decodeResponse(path, r, target)
+}
+
+// APIError is returned when the API answers with an unexpected status or a
+// body which is not valid JSON
+type APIError struct {
+	Path       string
+	StatusCode int
+
+	// Body is the beginning of the response body
+	Body string
+
+	// Err is the error of decoding the body
+	Err error
+}
+
+const bodyExcerptLength = 200
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: invalid response (status %d): %s: %q", e.Path, e.StatusCode, e.Err, e.Body)
+	}
+	return fmt.Sprintf("%s: unexpected status %d: %q", e.Path, e.StatusCode, e.Body)
+}
+
+func decodeResponse(path string, r *req.Resp, target interface{}) error {
+	body, err := r.ToBytes()
+	if err != nil {
+		return requestError(path, err)
+	}
+
+	excerpt := string(body)
+	if len(excerpt) > bodyExcerptLength {
+		excerpt = excerpt[:bodyExcerptLength] + "..."
+	}
+
+	statusCode := r.Response().StatusCode
+	if statusCode != http.StatusOK {
+		return &APIError{Path: path, StatusCode: statusCode, Body: excerpt}
+	}
+
+	if err = json.Unmarshal(body, target); err != nil {
+		return &APIError{Path: path, StatusCode: statusCode, Body: excerpt, Err: err}
 	}
 
-	return fmt.Sprintf("%s%s", rev("ot.s//:sptth"), path)
+	return nil
+}
+
+// requestError describes a failed request by the path only, as the URL
+// contains the API token
+func requestError(path string, err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	return errors.New(fmt.Sprintf("%s: %s", path, err))
 }
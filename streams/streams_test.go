@@ -0,0 +1,77 @@
+package streams
+
+import (
+	"fmt"
+	"github.com/pboehm/series/config"
+	. "launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+func newTestStreams(status int, body string) (*Streams, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+
+	streams := NewStreams(config.StreamsProvider{Name: "test", BaseURL: server.URL, APIToken: "secret-token"})
+	return streams, server.Close
+}
+
+func (s *MySuite) TestAvailableSeries(c *C) {
+	streams, closeServer := newTestStreams(http.StatusOK, `{"series": [{"id": 1, "name": "Community"}]}`)
+	defer closeServer()
+
+	series, err := streams.AvailableSeries()
+	c.Assert(err, IsNil)
+	c.Assert(series, HasLen, 1)
+	c.Assert(series[0].Name, Equals, "Community")
+}
+
+func (s *MySuite) TestAPIErrorForUnexpectedStatus(c *C) {
+	streams, closeServer := newTestStreams(http.StatusForbidden, "invalid key")
+	defer closeServer()
+
+	_, err := streams.AvailableSeries()
+	apiErr, ok := err.(*APIError)
+	c.Assert(ok, Equals, true)
+	c.Assert(apiErr.Path, Equals, "/api/v1/series/list")
+	c.Assert(apiErr.StatusCode, Equals, http.StatusForbidden)
+	c.Assert(apiErr.Body, Equals, "invalid key")
+	c.Assert(apiErr.Err, IsNil)
+	c.Assert(err, ErrorMatches, `/api/v1/series/list: unexpected status 403: "invalid key"`)
+}
+
+func (s *MySuite) TestAPIErrorForInvalidJSON(c *C) {
+	streams, closeServer := newTestStreams(http.StatusOK, "<html>maintenance</html>")
+	defer closeServer()
+
+	_, err := streams.Seasons(&Series{Id: 1})
+	apiErr, ok := err.(*APIError)
+	c.Assert(ok, Equals, true)
+	c.Assert(apiErr.StatusCode, Equals, http.StatusOK)
+	c.Assert(apiErr.Err, NotNil)
+	c.Assert(err, ErrorMatches, `/api/v1/series/get: invalid response \(status 200\): .*: "<html>maintenance</html>"`)
+}
+
+func (s *MySuite) TestAPIErrorTruncatesBody(c *C) {
+	body := strings.Repeat("x", bodyExcerptLength+50)
+	streams, closeServer := newTestStreams(http.StatusInternalServerError, body)
+	defer closeServer()
+
+	_, err := streams.Episodes(&Series{Id: 1}, 1)
+	apiErr, ok := err.(*APIError)
+	c.Assert(ok, Equals, true)
+	c.Assert(apiErr.Body, Equals, body[:bodyExcerptLength]+"...")
+}
+
+func (s *MySuite) TestRequestErrorHidesToken(c *C) {
+	streams, closeServer := newTestStreams(http.StatusOK, "{}")
+	closeServer()
+
+	_, err := streams.AvailableSeries()
+	c.Assert(err, NotNil)
+	c.Assert(strings.Contains(err.Error(), "secret-token"), Equals, false)
+	c.Assert(err, ErrorMatches, "/api/v1/series/list: .*")
+}
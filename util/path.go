@@ -28,7 +28,3 @@ func IsDirectory(path string) bool {
 	}
 	return false
 }
-
-func HomeDirectory() string {
-	return os.Getenv("HOME")
-}
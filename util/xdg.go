@@ -0,0 +1,54 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+)
+
+// HomeDirectory returns the home directory of the user, which is also
+// determined when HOME is not set, e.g. in systemd units
+func HomeDirectory() (string, error) {
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		return home, nil
+	}
+
+	if current, err := user.Current(); err == nil && current.HomeDir != "" {
+		return current.HomeDir, nil
+	}
+
+	return "", errors.New("the home directory could not be determined, HOME is not set")
+}
+
+// ConfigHome returns $XDG_CONFIG_HOME or ~/.config
+func ConfigHome() (string, error) {
+	return xdgDirectory("XDG_CONFIG_HOME", ".config")
+}
+
+// DataHome returns $XDG_DATA_HOME or ~/.local/share
+func DataHome() (string, error) {
+	return xdgDirectory("XDG_DATA_HOME", ".local/share")
+}
+
+// StateHome returns $XDG_STATE_HOME or ~/.local/state
+func StateHome() (string, error) {
+	return xdgDirectory("XDG_STATE_HOME", ".local/state")
+}
+
+// xdgDirectory returns the directory from the environment variable or the
+// fallback within the home directory. Relative paths are ignored, as required
+// by the XDG Base Directory Specification.
+func xdgDirectory(variable, fallback string) (string, error) {
+	if directory := os.Getenv(variable); path.IsAbs(directory) {
+		return directory, nil
+	}
+
+	home, err := HomeDirectory()
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("%s is not set and %s", variable, err))
+	}
+
+	return path.Join(home, fallback), nil
+}
@@ -0,0 +1,48 @@
+package util
+
+import (
+	. "launchpad.net/gocheck"
+	"os"
+)
+
+func (s *MySuite) TestXDGDirectories(c *C) {
+	defer restoreEnv("HOME", "XDG_CONFIG_HOME", "XDG_DATA_HOME", "XDG_STATE_HOME")()
+
+	os.Setenv("HOME", "/home/user")
+	os.Setenv("XDG_CONFIG_HOME", "/etc/xdg-config")
+	os.Setenv("XDG_DATA_HOME", "relative/data")
+	os.Unsetenv("XDG_STATE_HOME")
+
+	configHome, err := ConfigHome()
+	c.Assert(err, IsNil)
+	c.Assert(configHome, Equals, "/etc/xdg-config")
+
+	// relative paths are ignored
+	dataHome, err := DataHome()
+	c.Assert(err, IsNil)
+	c.Assert(dataHome, Equals, "/home/user/.local/share")
+
+	stateHome, err := StateHome()
+	c.Assert(err, IsNil)
+	c.Assert(stateHome, Equals, "/home/user/.local/state")
+}
+
+// restoreEnv returns a function restoring the current values of the variables
+func restoreEnv(variables ...string) func() {
+	values := map[string]string{}
+	for _, variable := range variables {
+		if value, exists := os.LookupEnv(variable); exists {
+			values[variable] = value
+		}
+	}
+
+	return func() {
+		for _, variable := range variables {
+			if value, exists := values[variable]; exists {
+				os.Setenv(variable, value)
+			} else {
+				os.Unsetenv(variable)
+			}
+		}
+	}
+}